@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -14,17 +15,23 @@ import (
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/maskrapp/api/internal/config"
+	"github.com/maskrapp/api/internal/dns"
 	"github.com/maskrapp/api/internal/domains"
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/expiry"
 	"github.com/maskrapp/api/internal/global"
 	grpc_impl "github.com/maskrapp/api/internal/grpc"
 	"github.com/maskrapp/api/internal/healthcheck"
 	"github.com/maskrapp/api/internal/jwt"
 	"github.com/maskrapp/api/internal/mailer"
 	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/oidc"
 	main_api "github.com/maskrapp/api/internal/pb/main_api/v1"
 	"github.com/maskrapp/api/internal/ratelimit"
 	"github.com/maskrapp/api/internal/recaptcha"
 	"github.com/maskrapp/api/internal/routes"
+	"github.com/maskrapp/api/internal/sessions"
+	"github.com/maskrapp/api/internal/webauthn"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
@@ -59,28 +66,41 @@ func main() {
 		logrus.Panic(err)
 	}
 
-	domainService := domains.New(db, time.Minute*2)
+	domainService := domains.New(db, redis, time.Minute*2)
 	domainService.Start()
 
+	jwtKeys, err := jwt.ParseKeys(cfg.JWT.Keys)
+	if err != nil {
+		logrus.Panic(err)
+	}
+	jwtHandler := jwt.New(cfg.JWT.Secret, jwtKeys, 5*time.Minute, 24*time.Hour)
+
 	instances := &global.Instances{
-		Gorm:        db,
-		Redis:       redis,
-		RateLimiter: ratelimit.New(redis, 50, map[string]int{}),
-		Recaptcha:   recaptcha.New(cfg.Recaptcha.Secret),
-		JWT:         jwt.New(cfg.JWT.Secret, 5*time.Minute, 24*time.Hour),
-		Mailer:      mailer.New(cfg),
-		Domains:     domainService,
+		Gorm:         db,
+		Redis:        redis,
+		RateLimiter:  ratelimit.New(redis, 50, map[string]int{}),
+		Recaptcha:    recaptcha.New(cfg.Recaptcha.Secret),
+		JWT:          jwtHandler,
+		Mailer:       mailer.New(cfg),
+		Domains:      domainService,
+		DNS:          dns.New(net.DefaultResolver, cfg),
+		Entitlements: entitlements.New(db),
+		WebAuthn:     webauthn.New(cfg),
+		OIDC:         oidc.NewRegistry(cfg, &http.Client{Timeout: 10 * time.Second}),
+		Sessions:     sessions.New(db, jwtHandler, cfg.Production),
 	}
 
 	gCtx, cancel := global.WithCancel(global.NewContext(context.Background(), instances, cfg))
 
 	defer cancel()
 
-	err = instances.Gorm.AutoMigrate(&models.User{}, models.Email{}, models.EmailVerification{}, models.Mask{}, models.Provider{}, models.AccountVerification{}, models.Domain{}, models.PasswordResetVerification{})
+	err = models.Migrate(instances.Gorm)
 	if err != nil {
 		logrus.Panic(err)
 	}
 
+	expiry.New(db, time.Minute).Start()
+
 	fiber := fiber.New()
 	routes.Setup(gCtx, fiber)
 
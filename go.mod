@@ -3,6 +3,7 @@ module github.com/maskrapp/api
 go 1.19
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/go-redis/redis/v9 v9.0.0-rc.2
 	github.com/gofiber/fiber/v2 v2.42.0
 	github.com/golang/protobuf v1.5.3
@@ -0,0 +1,133 @@
+package apikeys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Scopes that can be granted to an API key. Keys can only be used on the route groups that they have a scope for.
+const (
+	MasksRead    = "masks:read"
+	MasksWrite   = "masks:write"
+	EmailsRead   = "emails:read"
+	EmailsWrite  = "emails:write"
+	DomainsRead  = "domains:read"
+	DomainsWrite = "domains:write"
+)
+
+// Scopes contains every scope that can be granted.
+var Scopes = []string{MasksRead, MasksWrite, EmailsRead, EmailsWrite, DomainsRead, DomainsWrite}
+
+// Prefix starts every API key, it tells keys apart from access tokens.
+const Prefix = "mk_"
+
+const (
+	prefixLength = 8
+	secretLength = 32
+	// lastUsedInterval limits how often the last used time of a key is written, so that not every request causes a write.
+	lastUsedInterval = time.Minute
+)
+
+var (
+	// ErrInvalid is returned when a key doesn't exist.
+	ErrInvalid = errors.New("invalid api key")
+	// ErrExpired is returned when a key has passed its expiry time.
+	ErrExpired = errors.New("api key is expired")
+)
+
+// IsKey reports whether the token is an API key rather than an access token.
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// ValidScope reports whether the scope exists.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the scope is in the list of granted scopes.
+func HasScope(granted []string, scope string) bool {
+	for _, s := range granted {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the hash under which a key is stored.
+// Keys are long random strings, so unlike passwords they don't need a slow hash, and the hash can be looked up directly.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate creates a key for the user. The plain key is only returned here, the record holds its hash and prefix.
+func Generate(db *gorm.DB, userID, name string, scopes []string, expiresAt int64) (string, *models.APIKey, error) {
+	prefix, err := utils.GenerateRandomString(prefixLength)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := utils.GenerateRandomString(secretLength)
+	if err != nil {
+		return "", nil, err
+	}
+	key := Prefix + prefix + "_" + secret
+	record := &models.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    Prefix + prefix,
+		KeyHash:   Hash(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	err = db.Create(record).Error
+	if err != nil {
+		return "", nil, err
+	}
+	return key, record, nil
+}
+
+// RevokeAll deletes every key of the user. Keys don't depend on the token version, so this is done when the password of the user changes
+// or two-factor authentication is disabled.
+func RevokeAll(db *gorm.DB, userID string) error {
+	return db.Delete(&models.APIKey{}, "user_id = ?", userID).Error
+}
+
+// Authenticate looks up the record of a key, and records that it has been used.
+func Authenticate(db *gorm.DB, key string) (*models.APIKey, error) {
+	record := &models.APIKey{}
+	err := db.Where("key_hash = ?", Hash(key)).Limit(1).Find(record).Error
+	if err != nil {
+		return nil, err
+	}
+	if record.ID == "" {
+		return nil, ErrInvalid
+	}
+	now := time.Now()
+	if record.ExpiresAt > 0 && record.ExpiresAt <= now.Unix() {
+		return nil, ErrExpired
+	}
+	if now.Unix()-record.LastUsedAt >= int64(lastUsedInterval.Seconds()) {
+		err = db.Model(&models.APIKey{}).Where("id = ?", record.ID).Update("last_used_at", now.Unix()).Error
+		if err != nil {
+			return nil, err
+		}
+		record.LastUsedAt = now.Unix()
+	}
+	return record, nil
+}
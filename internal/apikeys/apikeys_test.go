@@ -0,0 +1,30 @@
+package apikeys_test
+
+import (
+	"testing"
+
+	"github.com/maskrapp/api/internal/apikeys"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopes(t *testing.T) {
+	for _, scope := range apikeys.Scopes {
+		assert.True(t, apikeys.ValidScope(scope), scope)
+	}
+	assert.False(t, apikeys.ValidScope("account:write"))
+	assert.False(t, apikeys.ValidScope(""))
+
+	granted := []string{apikeys.MasksRead, apikeys.EmailsRead}
+	assert.True(t, apikeys.HasScope(granted, apikeys.MasksRead))
+	assert.False(t, apikeys.HasScope(granted, apikeys.MasksWrite))
+	assert.False(t, apikeys.HasScope(nil, apikeys.MasksRead))
+}
+
+func TestHash(t *testing.T) {
+	assert.True(t, apikeys.IsKey("mk_abcdefgh_secret"))
+	assert.False(t, apikeys.IsKey("eyJhbGciOiJSUzI1NiJ9.e30.sig"))
+
+	assert.Equal(t, apikeys.Hash("mk_key"), apikeys.Hash("mk_key"))
+	assert.NotEqual(t, apikeys.Hash("mk_key"), apikeys.Hash("mk_kez"))
+	assert.Len(t, apikeys.Hash("mk_key"), 64)
+}
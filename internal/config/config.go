@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -22,12 +23,17 @@ type Config struct {
 		Secret string
 	}
 	ZeptoMail struct {
-		EmailToken   string
-		TemplateKey  string
-		EmailAddress string
+		EmailToken                     string
+		TemplateKey                    string // Used for the verification codes.
+		MagicLinkTemplateKey           string
+		AccountDeletedTemplateKey      string
+		PrimaryEmailChangedTemplateKey string
+		ExportTemplateKey              string
+		EmailAddress                   string
 	}
 	JWT struct {
 		Secret string
+		Keys   string // See jwt.ParseKeys for the format.
 	}
 	OAuth struct {
 		GoogleRedirectURL string
@@ -40,9 +46,55 @@ type Config struct {
 	GRPC struct {
 		Port string
 	}
+	Domains struct {
+		MXHost        string
+		SPFInclude    string
+		DKIMSelector  string
+		DKIMPublicKey string
+	}
+	OIDC struct {
+		Providers []OIDCProvider
+	}
+	MagicLink struct {
+		URL string
+	}
+	WebAuthn struct {
+		RPID    string
+		RPName  string
+		Origins []string
+	}
+	Export struct {
+		URL string // The download links of exports point here.
+	}
+	Account struct {
+		ReserveDeletedMasks bool // Keeps the mask addresses of deleted accounts from being registered again.
+	}
 	Production bool
 }
 
+// OIDCProvider configures an OpenID Connect or OAuth 2.0 sign in provider.
+// Endpoints that aren't set are read from the discovery document of the issuer.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Claims       OIDCClaims
+}
+
+// OIDCClaims maps the fields of the userinfo response of a provider.
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified string // When empty, emails of the provider are never treated as verified.
+	Name          string
+}
+
 func New() *Config {
 	cfg := &Config{}
 
@@ -59,23 +111,93 @@ func New() *Config {
 
 	cfg.ZeptoMail.EmailToken = os.Getenv("MAIL_TOKEN")
 	cfg.ZeptoMail.TemplateKey = os.Getenv("MAIL_TEMPLATE_KEY")
+	cfg.ZeptoMail.MagicLinkTemplateKey = os.Getenv("MAIL_MAGIC_LINK_TEMPLATE_KEY")
+	cfg.ZeptoMail.AccountDeletedTemplateKey = os.Getenv("MAIL_ACCOUNT_DELETED_TEMPLATE_KEY")
+	cfg.ZeptoMail.PrimaryEmailChangedTemplateKey = os.Getenv("MAIL_PRIMARY_EMAIL_CHANGED_TEMPLATE_KEY")
+	cfg.ZeptoMail.ExportTemplateKey = os.Getenv("MAIL_EXPORT_TEMPLATE_KEY")
 	cfg.ZeptoMail.EmailAddress = os.Getenv("MAIL_ADDRESS")
 
 	cfg.JWT.Secret = os.Getenv("SECRET_KEY")
+	cfg.JWT.Keys = os.Getenv("JWT_KEYS")
 
 	cfg.OAuth.GoogleClientId = os.Getenv("GOOGLE_CLIENT_ID")
 	cfg.OAuth.GoogleRedirectURL = os.Getenv("GOOGLE_REDIRECT")
 	cfg.OAuth.GoogleSecret = os.Getenv("GOOGLE_SECRET")
 
+	cfg.OIDC.Providers = oidcProviders(cfg)
+
 	cfg.Logger.LogLevel = getOrDefault("LOG_LEVEL", "debug")
 
 	cfg.GRPC.Port = getOrDefault("GRPC_PORT", "50051")
 
+	cfg.Domains.MXHost = getOrDefault("DOMAINS_MX_HOST", "mx.maskr.app")
+	cfg.Domains.SPFInclude = getOrDefault("DOMAINS_SPF_INCLUDE", "spf.maskr.app")
+	cfg.Domains.DKIMSelector = getOrDefault("DOMAINS_DKIM_SELECTOR", "maskr")
+	cfg.Domains.DKIMPublicKey = os.Getenv("DOMAINS_DKIM_PUBLIC_KEY")
+
+	cfg.MagicLink.URL = getOrDefault("MAGIC_LINK_URL", "https://maskr.app/signin/magic")
+
+	cfg.WebAuthn.RPID = getOrDefault("WEBAUTHN_RP_ID", "maskr.app")
+	cfg.WebAuthn.RPName = getOrDefault("WEBAUTHN_RP_NAME", "Maskr")
+	cfg.WebAuthn.Origins = strings.Split(getOrDefault("WEBAUTHN_ORIGINS", "https://maskr.app"), ",")
+
+	cfg.Export.URL = getOrDefault("EXPORT_URL", "https://api.maskr.app/export")
+
+	cfg.Account.ReserveDeletedMasks = getOrDefault("ACCOUNT_RESERVE_DELETED_MASKS", "true") == "true"
+
 	cfg.Production = getOrDefault("PRODUCTION", "true") == "true"
 
 	return cfg
 }
 
+// oidcProviders reads the providers listed in OIDC_PROVIDERS, which are configured with OIDC_<NAME>_* variables.
+// Google is added when its client id is set.
+func oidcProviders(cfg *Config) []OIDCProvider {
+	providers := make([]OIDCProvider, 0)
+	if cfg.OAuth.GoogleClientId != "" {
+		providers = append(providers, OIDCProvider{
+			Name:         "google",
+			Issuer:       "https://accounts.google.com",
+			UserInfoURL:  "https://www.googleapis.com/oauth2/v2/userinfo",
+			ClientID:     cfg.OAuth.GoogleClientId,
+			ClientSecret: cfg.OAuth.GoogleSecret,
+			RedirectURL:  cfg.OAuth.GoogleRedirectURL,
+			Scopes:       []string{"profile", "email"},
+			Claims: OIDCClaims{
+				Subject:       "id",
+				Email:         "email",
+				EmailVerified: "verified_email",
+				Name:          "name",
+			},
+		})
+	}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			AuthURL:      os.Getenv(prefix + "AUTH_URL"),
+			TokenURL:     os.Getenv(prefix + "TOKEN_URL"),
+			UserInfoURL:  os.Getenv(prefix + "USERINFO_URL"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getOrDefault(prefix+"SCOPES", "openid email profile")),
+			Claims: OIDCClaims{
+				Subject:       getOrDefault(prefix+"CLAIM_SUBJECT", "sub"),
+				Email:         getOrDefault(prefix+"CLAIM_EMAIL", "email"),
+				EmailVerified: getOrDefault(prefix+"CLAIM_EMAIL_VERIFIED", "email_verified"),
+				Name:          getOrDefault(prefix+"CLAIM_NAME", "name"),
+			},
+		})
+	}
+	return providers
+}
+
 func getOrDefault(variable string, def string) string {
 	result, ok := os.LookupEnv(variable)
 	if !ok {
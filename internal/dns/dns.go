@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/maskrapp/api/internal/config"
+)
+
+// VerificationPrefix is the name of the TXT record, relative to the domain, that holds the ownership token.
+const VerificationPrefix = "_maskr-verification"
+
+// Resolver looks up DNS records. *net.Resolver implements it, tests can provide their own records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+type Checker struct {
+	resolver      Resolver
+	mxHost        string
+	spfInclude    string
+	dkimSelector  string
+	dkimPublicKey string
+}
+
+// Result holds which of the mail records of a domain are set up correctly.
+type Result struct {
+	MX   bool `json:"mx"`
+	SPF  bool `json:"spf"`
+	DKIM bool `json:"dkim"`
+}
+
+// Record is a DNS record that has to be created to use a domain.
+type Record struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// New creates a new Checker instance.
+func New(resolver Resolver, cfg *config.Config) *Checker {
+	return &Checker{
+		resolver:      resolver,
+		mxHost:        normalize(cfg.Domains.MXHost),
+		spfInclude:    cfg.Domains.SPFInclude,
+		dkimSelector:  cfg.Domains.DKIMSelector,
+		dkimPublicKey: cfg.Domains.DKIMPublicKey,
+	}
+}
+
+// Records returns the records a user has to create for the given domain and verification token.
+func (c *Checker) Records(domain, token string) []Record {
+	dkim := "v=DKIM1; k=rsa; p=" + c.dkimPublicKey
+	return []Record{
+		{Type: "TXT", Name: VerificationPrefix + "." + domain, Value: verificationValue(token)},
+		{Type: "MX", Name: domain, Value: c.mxHost},
+		{Type: "TXT", Name: domain, Value: fmt.Sprintf("v=spf1 include:%v ~all", c.spfInclude)},
+		{Type: "TXT", Name: c.dkimSelector + "._domainkey." + domain, Value: dkim},
+	}
+}
+
+// VerifyOwnership reports whether the domain has a TXT record that contains the verification token.
+func (c *Checker) VerifyOwnership(ctx context.Context, domain, token string) (bool, error) {
+	records, err := c.lookupTXT(ctx, VerificationPrefix+"."+domain)
+	if err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == verificationValue(token) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Check looks up the MX, SPF and DKIM records of the domain.
+func (c *Checker) Check(ctx context.Context, domain string) (*Result, error) {
+	result := &Result{}
+
+	mxRecords, err := c.resolver.LookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	for _, mx := range mxRecords {
+		if normalize(mx.Host) == c.mxHost {
+			result.MX = true
+		}
+	}
+
+	txtRecords, err := c.lookupTXT(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range txtRecords {
+		if !strings.HasPrefix(record, "v=spf1") {
+			continue
+		}
+		for _, mechanism := range strings.Fields(record) {
+			if strings.TrimLeft(mechanism, "+") == "include:"+c.spfInclude {
+				result.SPF = true
+			}
+		}
+	}
+
+	dkimRecords, err := c.lookupTXT(ctx, c.dkimSelector+"._domainkey."+domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range dkimRecords {
+		tags := parseTags(record)
+		if tags["v"] != "DKIM1" || tags["p"] == "" {
+			continue
+		}
+		if c.dkimPublicKey == "" || tags["p"] == c.dkimPublicKey {
+			result.DKIM = true
+		}
+	}
+	return result, nil
+}
+
+// lookupTXT looks up TXT records, a missing record is not treated as an error.
+func (c *Checker) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, err := c.resolver.LookupTXT(ctx, name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	return records, nil
+}
+
+func verificationValue(token string) string {
+	return "maskr-verification=" + token
+}
+
+func isNotFound(err error) bool {
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && dnsErr.IsNotFound
+}
+
+func normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// parseTags parses a `tag=value; tag=value` record, as used by DKIM.
+func parseTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		split := strings.SplitN(part, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+		tags[strings.TrimSpace(split[0])] = strings.Join(strings.Fields(split[1]), "")
+	}
+	return tags
+}
@@ -0,0 +1,81 @@
+package dns_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/maskrapp/api/internal/config"
+	"github.com/maskrapp/api/internal/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResolver struct {
+	txt map[string][]string
+	mx  map[string][]*net.MX
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := f.txt[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	records, ok := f.mx[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func newChecker(resolver dns.Resolver) *dns.Checker {
+	cfg := &config.Config{}
+	cfg.Domains.MXHost = "mx.maskr.app"
+	cfg.Domains.SPFInclude = "spf.maskr.app"
+	cfg.Domains.DKIMSelector = "maskr"
+	cfg.Domains.DKIMPublicKey = "MIGfMA0"
+	return dns.New(resolver, cfg)
+}
+
+func TestVerifyOwnership(t *testing.T) {
+	checker := newChecker(&fakeResolver{
+		txt: map[string][]string{
+			"_maskr-verification.example.com": {"something-else", "maskr-verification=token123"},
+		},
+	})
+	ok, err := checker.VerifyOwnership(context.Background(), "example.com", "token123")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = checker.VerifyOwnership(context.Background(), "example.com", "wrong")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = checker.VerifyOwnership(context.Background(), "missing.com", "token123")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestCheck(t *testing.T) {
+	checker := newChecker(&fakeResolver{
+		txt: map[string][]string{
+			"example.com":                  {"v=spf1 include:_spf.google.com include:spf.maskr.app ~all"},
+			"maskr._domainkey.example.com": {"v=DKIM1; k=rsa; p=MIGfMA0"},
+			"other.com":                    {"v=spf1 -all"},
+		},
+		mx: map[string][]*net.MX{
+			"example.com": {{Host: "MX.maskr.app.", Pref: 10}},
+			"other.com":   {{Host: "mx.other.com.", Pref: 10}},
+		},
+	})
+	result, err := checker.Check(context.Background(), "example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &dns.Result{MX: true, SPF: true, DKIM: true}, result)
+
+	result, err = checker.Check(context.Background(), "other.com")
+	assert.Nil(t, err)
+	assert.Equal(t, &dns.Result{}, result)
+}
@@ -1,27 +1,34 @@
 package domains
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v9"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// refreshChannel is the redis channel on which Refresh tells the other replicas to fetch the domains again.
+const refreshChannel = "domains:refresh"
+
 type Domains struct {
 	db       *gorm.DB
+	redis    *redis.Client
 	interval time.Duration
 	mutex    sync.RWMutex
 	domains  []*models.Domain
 }
 
 // New creates a new Domains instance.
-func New(db *gorm.DB, interval time.Duration) *Domains {
+func New(db *gorm.DB, redis *redis.Client, interval time.Duration) *Domains {
 	return &Domains{
 		db:       db,
+		redis:    redis,
 		interval: interval,
 		mutex:    sync.RWMutex{},
 		domains:  make([]*models.Domain, 0),
@@ -30,7 +37,7 @@ func New(db *gorm.DB, interval time.Duration) *Domains {
 
 func (d *Domains) update() {
 	var domains []*models.Domain
-	err := d.db.Find(&domains).Error
+	err := d.db.Find(&domains, "user_id IS NULL OR verified").Error
 	if err != nil {
 		logrus.Errorf("db error(updateAvailableDomains): %v", err)
 		return
@@ -41,7 +48,7 @@ func (d *Domains) update() {
 	logrus.Debugf("available domains: %v", d.domains)
 }
 
-// Get retrieves a domain under the given input.
+// Get retrieves a domain under the given input. This includes the verified custom domains of every user.
 func (d *Domains) Get(domain string) (*models.Domain, error) {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
@@ -53,14 +60,55 @@ func (d *Domains) Get(domain string) (*models.Domain, error) {
 	return nil, errors.New("domain not found")
 }
 
-// Values returns the recently fetched domains.
+// GetForUser retrieves a domain under the given input, if the user is allowed to use it.
+func (d *Domains) GetForUser(domain, userID string) (*models.Domain, error) {
+	result, err := d.Get(domain)
+	if err != nil {
+		return nil, err
+	}
+	if result.UserID != nil && *result.UserID != userID {
+		return nil, errors.New("domain not found")
+	}
+	return result, nil
+}
+
+// Values returns the recently fetched domains of the service, custom domains are left out.
 func (d *Domains) Values() []*models.Domain {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
-	return d.domains
+	values := make([]*models.Domain, 0, len(d.domains))
+	for _, v := range d.domains {
+		if v.UserID == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ValuesForUser returns the recently fetched domains that the user is allowed to use.
+func (d *Domains) ValuesForUser(userID string) []*models.Domain {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	values := make([]*models.Domain, 0, len(d.domains))
+	for _, v := range d.domains {
+		if v.UserID == nil || *v.UserID == userID {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Refresh fetches the domains right away, instead of waiting for the next interval.
+// The other replicas are told to fetch them as well, so that they don't keep serving a custom domain to its previous owner.
+func (d *Domains) Refresh() {
+	d.update()
+	err := d.redis.Publish(context.Background(), refreshChannel, "").Err()
+	if err != nil {
+		logrus.Errorf("redis error(refreshDomains): %v", err)
+	}
 }
 
-// Start starts the domain fetching task.
+// Start starts the domain fetching task, along with the listener for the refreshes of other replicas.
 func (d *Domains) Start() {
 	go func() {
 		for {
@@ -68,4 +116,9 @@ func (d *Domains) Start() {
 			time.Sleep(d.interval)
 		}
 	}()
+	go func() {
+		for range d.redis.Subscribe(context.Background(), refreshChannel).Channel() {
+			d.update()
+		}
+	}()
 }
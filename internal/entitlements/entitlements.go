@@ -0,0 +1,147 @@
+package entitlements
+
+import (
+	"errors"
+
+	"github.com/maskrapp/api/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMaskLimit         = errors.New("mask limit reached")
+	ErrEmailLimit        = errors.New("email limit reached")
+	ErrCustomDomainLimit = errors.New("custom domain limit reached")
+	ErrPremiumDomain     = errors.New("domain requires a premium plan")
+)
+
+type Entitlements struct {
+	db *gorm.DB
+}
+
+// Usage holds how much of each limit a user is using.
+type Usage struct {
+	Masks         int64 `json:"masks"`
+	Emails        int64 `json:"emails"`
+	CustomDomains int64 `json:"custom_domains"`
+}
+
+// New creates a new Entitlements instance.
+func New(db *gorm.DB) *Entitlements {
+	return &Entitlements{db: db}
+}
+
+// Plan retrieves the plan of the user.
+func (e *Entitlements) Plan(userID string) (*models.Plan, error) {
+	plan := &models.Plan{}
+	err := e.db.Table("plans").Select("plans.*").Joins("inner join users on users.plan_id = plans.id").Where("users.id = ?", userID).First(plan).Error
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Usage counts the masks, emails and custom domains of the user.
+func (e *Entitlements) Usage(userID string) (*Usage, error) {
+	usage := &Usage{}
+	err := e.db.Model(&models.Mask{}).Where("user_id = ?", userID).Count(&usage.Masks).Error
+	if err != nil {
+		return nil, err
+	}
+	err = e.db.Model(&models.Email{}).Where("user_id = ?", userID).Count(&usage.Emails).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.CustomDomains, err = e.countCustomDomains(userID)
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CanCreateMask returns ErrMaskLimit when the user can't create another mask.
+func (e *Entitlements) CanCreateMask(userID string) error {
+	plan, err := e.Plan(userID)
+	if err != nil {
+		return err
+	}
+	return e.checkLimit(plan.MaxMasks, &models.Mask{}, userID, ErrMaskLimit)
+}
+
+// CanAddEmail returns ErrEmailLimit when the user can't add another email.
+func (e *Entitlements) CanAddEmail(userID string) error {
+	plan, err := e.Plan(userID)
+	if err != nil {
+		return err
+	}
+	return e.checkLimit(plan.MaxEmails, &models.Email{}, userID, ErrEmailLimit)
+}
+
+// CanAddCustomDomain returns ErrCustomDomainLimit when the user can't register another custom domain.
+// Unverified domains count towards the limit as well.
+func (e *Entitlements) CanAddCustomDomain(userID string) error {
+	plan, err := e.Plan(userID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxCustomDomains == models.Unlimited {
+		return nil
+	}
+	count, err := e.countCustomDomains(userID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(plan.MaxCustomDomains) {
+		return ErrCustomDomainLimit
+	}
+	return nil
+}
+
+// CanUseDomain returns ErrPremiumDomain when the domain isn't free and the plan of the user doesn't include premium domains.
+// Custom domains can always be used by their owner.
+func (e *Entitlements) CanUseDomain(userID string, domain *models.Domain) error {
+	if domain.Free {
+		return nil
+	}
+	plan, err := e.Plan(userID)
+	if err != nil {
+		return err
+	}
+	return AllowsDomain(plan, domain)
+}
+
+// AllowsDomain returns ErrPremiumDomain when the plan doesn't allow the domain to be used.
+func AllowsDomain(plan *models.Plan, domain *models.Domain) error {
+	if domain.Free || domain.UserID != nil || plan.PremiumDomains {
+		return nil
+	}
+	return ErrPremiumDomain
+}
+
+func (e *Entitlements) checkLimit(limit int, model interface{}, userID string, limitErr error) error {
+	if limit == models.Unlimited {
+		return nil
+	}
+	var count int64
+	err := e.db.Model(model).Where("user_id = ?", userID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		return limitErr
+	}
+	return nil
+}
+
+// countCustomDomains counts the verified custom domains and the pending domain claims of the user.
+func (e *Entitlements) countCustomDomains(userID string) (int64, error) {
+	var domains, claims int64
+	err := e.db.Model(&models.Domain{}).Where("user_id = ?", userID).Count(&domains).Error
+	if err != nil {
+		return 0, err
+	}
+	err = e.db.Model(&models.DomainClaim{}).Where("user_id = ?", userID).Count(&claims).Error
+	if err != nil {
+		return 0, err
+	}
+	return domains + claims, nil
+}
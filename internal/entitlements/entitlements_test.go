@@ -0,0 +1,20 @@
+package entitlements_test
+
+import (
+	"testing"
+
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowsDomain(t *testing.T) {
+	free := &models.Plan{PremiumDomains: false}
+	premium := &models.Plan{PremiumDomains: true}
+	owner := "user"
+
+	assert.Nil(t, entitlements.AllowsDomain(free, &models.Domain{Domain: "maskr.app", Free: true}))
+	assert.Equal(t, entitlements.ErrPremiumDomain, entitlements.AllowsDomain(free, &models.Domain{Domain: "maskr.me"}))
+	assert.Nil(t, entitlements.AllowsDomain(free, &models.Domain{Domain: "example.com", UserID: &owner}))
+	assert.Nil(t, entitlements.AllowsDomain(premium, &models.Domain{Domain: "maskr.me"}))
+}
@@ -0,0 +1,54 @@
+package expiry
+
+import (
+	"time"
+
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Condition matches masks that have passed their expiry time or have received their maximum amount of messages.
+// The first argument is the current unix timestamp.
+const Condition = "((expires_at > 0 AND expires_at <= ?) OR (max_received > 0 AND messages_received >= max_received))"
+
+type Expiry struct {
+	db       *gorm.DB
+	interval time.Duration
+}
+
+// New creates a new Expiry instance.
+func New(db *gorm.DB, interval time.Duration) *Expiry {
+	return &Expiry{
+		db:       db,
+		interval: interval,
+	}
+}
+
+func (e *Expiry) update() {
+	now := time.Now().Unix()
+
+	result := e.db.Where("delete_on_expiry AND "+Condition, now).Delete(&models.Mask{})
+	if result.Error != nil {
+		logrus.Errorf("db error(deleteExpiredMasks): %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		logrus.Debugf("deleted %v expired masks", result.RowsAffected)
+	}
+
+	result = e.db.Model(&models.Mask{}).Where("enabled AND "+Condition, now).Update("enabled", false)
+	if result.Error != nil {
+		logrus.Errorf("db error(disableExpiredMasks): %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		logrus.Debugf("disabled %v expired masks", result.RowsAffected)
+	}
+}
+
+// Start starts the mask expiry task.
+func (e *Expiry) Start() {
+	go func() {
+		for {
+			e.update()
+			time.Sleep(e.interval)
+		}
+	}()
+}
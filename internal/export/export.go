@@ -0,0 +1,363 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/maskrapp/api/internal/models"
+	"gorm.io/gorm"
+)
+
+// Formats of an export.
+const (
+	FormatJSON = "json"
+	FormatZip  = "zip" // A zip archive with a CSV file per table.
+)
+
+// Export holds everything that is stored about a user. Secrets like password hashes and key hashes are left out.
+type Export struct {
+	Account        Account         `json:"account"`
+	Emails         []Email         `json:"emails"`
+	Masks          []Mask          `json:"masks"`
+	MaskRules      []MaskRule      `json:"mask_rules"`
+	ReverseAliases []ReverseAlias  `json:"reverse_aliases"`
+	Domains        []Domain        `json:"domains"`
+	Providers      []Provider      `json:"providers"`
+	Sessions       []Session       `json:"sessions"`
+	Passkeys       []Passkey       `json:"passkeys"`
+	APIKeys        []APIKey        `json:"api_keys"`
+	SecurityEvents []SecurityEvent `json:"security_events"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type Account struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	Plan        string    `json:"plan"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Email struct {
+	Email      string    `json:"email"`
+	IsPrimary  bool      `json:"is_primary"`
+	IsVerified bool      `json:"is_verified"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Mask struct {
+	Mask              string    `json:"mask"`
+	Enabled           bool      `json:"enabled"`
+	Recipients        []string  `json:"recipients"`
+	Label             string    `json:"label"`
+	Note              string    `json:"note"`
+	UsedOn            string    `json:"used_on"`
+	MessagesReceived  int       `json:"messages_received"`
+	MessagesForwarded int       `json:"messages_forwarded"`
+	ExpiresAt         int64     `json:"expires_at"`
+	MaxReceived       int       `json:"max_received"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type MaskRule struct {
+	Mask    string `json:"mask"`
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+type ReverseAlias struct {
+	Alias     string    `json:"alias"`
+	Mask      string    `json:"mask"`
+	Sender    string    `json:"sender"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Domain struct {
+	Domain    string    `json:"domain"`
+	Verified  bool      `json:"verified"`
+	CatchAll  bool      `json:"catch_all"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Provider struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Session struct {
+	DeviceName string    `json:"device_name"`
+	Provider   string    `json:"provider"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastUsedAt int64     `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Passkey struct {
+	Name       string    `json:"name"`
+	LastUsedAt int64     `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  int64     `json:"expires_at"`
+	LastUsedAt int64     `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type SecurityEvent struct {
+	Type      string    `json:"type"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidFormat reports whether the format exists.
+func ValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatZip
+}
+
+// Build collects the data of the user.
+func Build(db *gorm.DB, userID string) (*Export, error) {
+	user := &models.User{}
+	err := db.Preload("Plan").First(user, "id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	export := &Export{
+		Account: Account{
+			ID:          user.ID,
+			Email:       user.Email,
+			TOTPEnabled: user.TOTPEnabled,
+			CreatedAt:   user.CreatedAt,
+		},
+		CreatedAt: time.Now(),
+	}
+	if user.Plan != nil {
+		export.Account.Plan = user.Plan.Name
+	}
+
+	var emails []*models.Email
+	err = db.Where("user_id = ?", userID).Order("id ASC").Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		export.Emails = append(export.Emails, Email{Email: e.Email, IsPrimary: e.IsPrimary, IsVerified: e.IsVerified, CreatedAt: e.CreatedAt})
+	}
+
+	var recipients []struct {
+		MaskAddress string
+		Email       string
+	}
+	err = db.Table("mask_recipients").Select("mask_recipients.mask_address, emails.email").
+		Joins("INNER JOIN emails ON emails.id = mask_recipients.email_id").
+		Where("emails.user_id = ?", userID).Scan(&recipients).Error
+	if err != nil {
+		return nil, err
+	}
+	recipientsByMask := make(map[string][]string)
+	for _, r := range recipients {
+		recipientsByMask[r.MaskAddress] = append(recipientsByMask[r.MaskAddress], r.Email)
+	}
+	var masks []*models.Mask
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&masks).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range masks {
+		export.Masks = append(export.Masks, Mask{
+			Mask:              m.Mask,
+			Enabled:           m.Enabled,
+			Recipients:        recipientsByMask[m.Mask],
+			Label:             m.Label,
+			Note:              m.Note,
+			UsedOn:            m.UsedOn,
+			MessagesReceived:  m.MessagesReceived,
+			MessagesForwarded: m.MessagesForwarded,
+			ExpiresAt:         m.ExpiresAt,
+			MaxReceived:       m.MaxReceived,
+			CreatedAt:         m.CreatedAt,
+		})
+	}
+
+	userMasks := db.Model(&models.Mask{}).Select("mask").Where("user_id = ?", userID)
+	var rules []*models.MaskRule
+	err = db.Where("mask_address IN (?)", userMasks).Order("id ASC").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		export.MaskRules = append(export.MaskRules, MaskRule{Mask: r.MaskAddress, Type: r.Type, Pattern: r.Pattern, Action: r.Action})
+	}
+	var aliases []*models.ReverseAlias
+	err = db.Where("mask_address IN (?)", userMasks).Order("created_at ASC").Find(&aliases).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aliases {
+		export.ReverseAliases = append(export.ReverseAliases, ReverseAlias{Alias: a.Alias, Mask: a.MaskAddress, Sender: a.Sender, CreatedAt: a.CreatedAt})
+	}
+
+	var domains []*models.Domain
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range domains {
+		export.Domains = append(export.Domains, Domain{Domain: d.Domain, Verified: d.Verified, CatchAll: d.CatchAll, CreatedAt: d.CreatedAt})
+	}
+	var claims []*models.DomainClaim
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&claims).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range claims {
+		export.Domains = append(export.Domains, Domain{Domain: d.Domain, CreatedAt: d.CreatedAt})
+	}
+
+	var providers []*models.Provider
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&providers).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range providers {
+		export.Providers = append(export.Providers, Provider{
+			Provider:  p.ProviderName,
+			Subject:   strings.TrimPrefix(p.ID, p.ProviderName+":"),
+			CreatedAt: p.CreatedAt,
+		})
+	}
+
+	var sessions []*models.Session
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		export.Sessions = append(export.Sessions, Session{DeviceName: s.DeviceName, Provider: s.Provider, UserAgent: s.UserAgent, IP: s.IP, LastUsedAt: s.LastUsedAt, CreatedAt: s.CreatedAt})
+	}
+
+	var passkeys []*models.WebAuthnCredential
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&passkeys).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range passkeys {
+		export.Passkeys = append(export.Passkeys, Passkey{Name: p.Name, LastUsedAt: p.LastUsedAt, CreatedAt: p.CreatedAt})
+	}
+
+	var keys []*models.APIKey
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		export.APIKeys = append(export.APIKeys, APIKey{Name: k.Name, Prefix: k.Prefix, Scopes: k.Scopes, ExpiresAt: k.ExpiresAt, LastUsedAt: k.LastUsedAt, CreatedAt: k.CreatedAt})
+	}
+
+	var events []*models.SecurityEvent
+	err = db.Where("user_id = ?", userID).Order("created_at ASC").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		export.SecurityEvents = append(export.SecurityEvents, SecurityEvent{Type: e.Type, IP: e.IP, UserAgent: e.UserAgent, CreatedAt: e.CreatedAt})
+	}
+	return export, nil
+}
+
+// Encode encodes the export in the given format.
+func (e *Export) Encode(format string) ([]byte, error) {
+	if format == FormatZip {
+		return e.Zip()
+	}
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Zip creates a zip archive with a CSV file for the account and every table of the export.
+func (e *Export) Zip() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	tables := []struct {
+		name string
+		rows interface{}
+	}{
+		{"account", []Account{e.Account}},
+		{"emails", e.Emails},
+		{"masks", e.Masks},
+		{"mask_rules", e.MaskRules},
+		{"reverse_aliases", e.ReverseAliases},
+		{"domains", e.Domains},
+		{"providers", e.Providers},
+		{"sessions", e.Sessions},
+		{"passkeys", e.Passkeys},
+		{"api_keys", e.APIKeys},
+		{"security_events", e.SecurityEvents},
+	}
+	for _, table := range tables {
+		w, err := archive.Create(table.name + ".csv")
+		if err != nil {
+			return nil, err
+		}
+		err = writeCSV(w, table.rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := archive.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCSV writes a slice of structs as CSV, the json names of the fields are used as the header.
+func writeCSV(w interface{ Write([]byte) (int, error) }, rows interface{}) error {
+	writer := csv.NewWriter(w)
+	value := reflect.ValueOf(rows)
+	rowType := value.Type().Elem()
+	header := make([]string, rowType.NumField())
+	for i := range header {
+		header[i] = strings.Split(rowType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < value.Len(); i++ {
+		row := value.Index(i)
+		record := make([]string, row.NumField())
+		for j := range record {
+			record[j] = formatField(row.Field(j).Interface())
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatField(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
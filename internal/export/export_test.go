@@ -0,0 +1,59 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/maskrapp/api/internal/export"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZip(t *testing.T) {
+	created := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := &export.Export{
+		Account: export.Account{ID: "user", Email: "user@example.com", Plan: "Free", CreatedAt: created},
+		Masks: []export.Mask{
+			{Mask: "one@maskr.app", Enabled: true, Recipients: []string{"a@example.com", "b@example.com"}, Note: "with, comma", CreatedAt: created},
+		},
+	}
+	data, err := e.Encode(export.FormatZip)
+	assert.Nil(t, err)
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.Nil(t, err)
+	files := make(map[string][][]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		assert.Nil(t, err)
+		records, err := csv.NewReader(r).ReadAll()
+		assert.Nil(t, err)
+		files[f.Name] = records
+	}
+	assert.Len(t, files, 11)
+	assert.Len(t, files["emails.csv"], 1, "empty tables only have a header")
+
+	masks := files["masks.csv"]
+	assert.Len(t, masks, 2)
+	assert.Equal(t, "mask", masks[0][0])
+	assert.Equal(t, "one@maskr.app", masks[1][0])
+	assert.Equal(t, "a@example.com b@example.com", masks[1][2])
+	assert.Equal(t, "with, comma", masks[1][4])
+	assert.Equal(t, "2023-03-01T12:00:00Z", masks[1][10])
+
+	assert.Equal(t, []string{"user", "user@example.com", "Free", "false", "2023-03-01T12:00:00Z"}, files["account.csv"][1])
+}
+
+func TestJSON(t *testing.T) {
+	e := &export.Export{Account: export.Account{ID: "user"}}
+	data, err := e.Encode(export.FormatJSON)
+	assert.Nil(t, err)
+	var decoded map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "user", decoded["account"].(map[string]interface{})["id"])
+	assert.True(t, export.ValidFormat(export.FormatJSON))
+	assert.False(t, export.ValidFormat("xml"))
+}
@@ -6,22 +6,32 @@ import (
 
 	"github.com/go-redis/redis/v9"
 	"github.com/maskrapp/api/internal/config"
+	"github.com/maskrapp/api/internal/dns"
 	"github.com/maskrapp/api/internal/domains"
+	"github.com/maskrapp/api/internal/entitlements"
 	"github.com/maskrapp/api/internal/jwt"
 	"github.com/maskrapp/api/internal/mailer"
+	"github.com/maskrapp/api/internal/oidc"
 	"github.com/maskrapp/api/internal/ratelimit"
 	"github.com/maskrapp/api/internal/recaptcha"
+	"github.com/maskrapp/api/internal/sessions"
+	"github.com/maskrapp/api/internal/webauthn"
 	"gorm.io/gorm"
 )
 
 type Instances struct {
-	Gorm        *gorm.DB
-	Redis       *redis.Client
-	RateLimiter *ratelimit.RateLimiter
-	Recaptcha   *recaptcha.Recaptcha
-	JWT         *jwt.JWTHandler
-	Mailer      *mailer.Mailer
-	Domains     *domains.Domains
+	Gorm         *gorm.DB
+	Redis        *redis.Client
+	RateLimiter  *ratelimit.RateLimiter
+	Recaptcha    *recaptcha.Recaptcha
+	JWT          *jwt.JWTHandler
+	Mailer       *mailer.Mailer
+	Domains      *domains.Domains
+	DNS          *dns.Checker
+	Entitlements *entitlements.Entitlements
+	WebAuthn     *webauthn.WebAuthn
+	OIDC         *oidc.Registry
+	Sessions     *sessions.Sessions
 }
 
 type Context interface {
@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// catchAllAllowed checks whether the catch-all policy of a custom domain accepts mail for an unknown address, without creating a mask.
+// gorm.ErrRecordNotFound is returned when the catch-all policy of the domain doesn't apply to the address, when the address is reserved,
+// or when the owner of the domain has reached the mask limit of their plan.
+func (b *mainApiServiceImpl) catchAllAllowed(domain *models.Domain, address string) error {
+	if domain.UserID == nil || !domain.CatchAll || domain.CatchAllEmailID == nil {
+		return gorm.ErrRecordNotFound
+	}
+	address = strings.ToLower(address)
+	if !utils.EmailRegex.MatchString(address) || strings.HasPrefix(address, utils.ReverseAliasPrefix) {
+		return gorm.ErrRecordNotFound
+	}
+
+	err := b.entitlements.CanCreateMask(*domain.UserID)
+	if err != nil {
+		if errors.Is(err, entitlements.ErrMaskLimit) {
+			return gorm.ErrRecordNotFound
+		}
+		return err
+	}
+
+	// The addresses of deleted accounts stay reserved, even when their domain is added again by someone else.
+	var result struct {
+		Found bool
+	}
+	err = b.db.Raw("SELECT EXISTS(SELECT 1 FROM reserved_masks WHERE mask = ?) AS found", address).Scan(&result).Error
+	if err != nil {
+		return err
+	}
+	if result.Found {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// catchAllMask creates a mask for an unknown address on a custom domain that has catch-all enabled.
+// It is only called when a message is received or replied to, see catchAllAllowed for the errors.
+func (b *mainApiServiceImpl) catchAllMask(domain *models.Domain, address string) (*models.Mask, error) {
+	err := b.catchAllAllowed(domain, address)
+	if err != nil {
+		return nil, err
+	}
+	address = strings.ToLower(address)
+
+	mask := &models.Mask{
+		Mask:       address,
+		Enabled:    true,
+		UserID:     *domain.UserID,
+		Recipients: []models.MaskRecipient{{MaskAddress: address, EmailID: *domain.CatchAllEmailID}},
+	}
+	err = b.db.Create(mask).Error
+	if err == nil {
+		return mask, nil
+	}
+	if !strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+		return nil, err
+	}
+
+	// Another message to the same address created the mask in the meantime.
+	existing := &models.Mask{}
+	err = b.db.First(existing, "mask = ?", address).Error
+	if err != nil {
+		return nil, err
+	}
+	if existing.UserID != *domain.UserID {
+		return nil, errors.New("catch-all mask is owned by another user")
+	}
+	return existing, nil
+}
@@ -2,11 +2,14 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/maskrapp/api/internal/domains"
+	"github.com/maskrapp/api/internal/entitlements"
 	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
 	stubs "github.com/maskrapp/api/internal/pb/main_api/v1"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
@@ -15,42 +18,60 @@ import (
 	"gorm.io/gorm"
 )
 
+// disableOnLimit disables a mask once the message that is being counted makes it reach its maximum amount of received messages.
+var disableOnLimit = gorm.Expr("CASE WHEN max_received > 0 AND messages_received + 1 >= max_received THEN false ELSE enabled END")
+
 type mainApiServiceImpl struct {
-	db      *gorm.DB
-	domains *domains.Domains
+	db           *gorm.DB
+	domains      *domains.Domains
+	entitlements *entitlements.Entitlements
 	stubs.UnimplementedMainAPIServiceServer
 }
 
 func NewMainAPIService(ctx global.Context) stubs.MainAPIServiceServer {
 	return &mainApiServiceImpl{
-		db:      ctx.Instances().Gorm,
-		domains: ctx.Instances().Domains,
+		db:           ctx.Instances().Gorm,
+		domains:      ctx.Instances().Domains,
+		entitlements: ctx.Instances().Entitlements,
 	}
 }
 
+// canUseDomain reports whether masks of the user are served on the domain. Custom domains are only valid for their owner.
+func canUseDomain(domain *models.Domain, userID string) bool {
+	return domain.UserID == nil || *domain.UserID == userID
+}
+
 func (b *mainApiServiceImpl) CheckMask(ctx context.Context, request *stubs.CheckMaskRequest) (*stubs.CheckMaskResponse, error) {
 	split := strings.Split(request.MaskAddress, "@")
 	if len(split) != 2 {
 		return nil, status.New(codes.InvalidArgument, "invalid mask address").Err()
 	}
 
-	if _, err := b.domains.Get(split[1]); err != nil {
+	domain, err := b.domains.Get(split[1])
+	if err != nil {
 		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
 	}
 
-	var result struct {
-		Found bool
+	mask := &models.Mask{}
+	err = b.db.Select("enabled, user_id, expires_at, max_received, messages_received").First(mask, "mask = ?", request.MaskAddress).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Catch-all masks are only created by GetMask once the message is actually received.
+		err = b.catchAllAllowed(domain, request.MaskAddress)
+		if err == nil {
+			return &stubs.CheckMaskResponse{Valid: true, Enabled: true}, nil
+		}
 	}
-	err := b.db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ?) AS found",
-		request.MaskAddress).Scan(&result).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &stubs.CheckMaskResponse{Valid: false}, status.New(codes.NotFound, "mask not found").Err()
+		}
 		logrus.Errorf("db error: %v", err)
 		return nil, status.New(codes.Unavailable, err.Error()).Err()
 	}
-	if !result.Found {
+	if !canUseDomain(domain, mask.UserID) {
 		return &stubs.CheckMaskResponse{Valid: false}, status.New(codes.NotFound, "mask not found").Err()
 	}
-	return &stubs.CheckMaskResponse{Valid: result.Found}, nil
+	return &stubs.CheckMaskResponse{Valid: true, Enabled: mask.Enabled && !mask.Expired()}, nil
 }
 func (b *mainApiServiceImpl) GetMask(ctx context.Context, request *stubs.GetMaskRequest) (*stubs.GetMaskResponse, error) {
 
@@ -59,24 +80,47 @@ func (b *mainApiServiceImpl) GetMask(ctx context.Context, request *stubs.GetMask
 		return nil, status.New(codes.InvalidArgument, "invalid mask address").Err()
 	}
 
-	if _, err := b.domains.Get(split[1]); err != nil {
+	domain, err := b.domains.Get(split[1])
+	if err != nil {
 		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
 	}
 
-	var res struct {
-		Email   string
-		Enabled bool
+	mask := &models.Mask{}
+	err = b.db.Select("mask, enabled, user_id, expires_at, max_received, messages_received").Where("mask = ?", request.MaskAddress).Find(mask).Error
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+	if mask.Mask == "" {
+		created, err := b.catchAllMask(domain, request.MaskAddress)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.Errorf("db error: %v", err)
+			return nil, status.New(codes.Unavailable, err.Error()).Err()
+		}
+		if created != nil {
+			mask = created
+		}
+	}
+	if !canUseDomain(domain, mask.UserID) {
+		return nil, status.New(codes.NotFound, "mask not found").Err()
 	}
 
-	err := b.db.Table("masks").Select("masks.enabled, emails.email").Joins("inner join emails on emails.id = masks.forward_to").Where("masks.mask = ?", request.MaskAddress).Find(&res).Error
+	var emails []string
+	err = b.db.Table("mask_recipients").Select("emails.email").Joins("inner join emails on emails.id = mask_recipients.email_id").Where("mask_recipients.mask_address = ?", request.MaskAddress).Order("mask_recipients.created_at ASC").Scan(&emails).Error
 	if err != nil {
 		logrus.Errorf("db error: %v", err)
 		return nil, status.New(codes.Unavailable, err.Error()).Err()
 	}
-	return &stubs.GetMaskResponse{
-		Email:   res.Email,
-		Enabled: res.Enabled,
-	}, nil
+
+	response := &stubs.GetMaskResponse{
+		Emails:  emails,
+		Enabled: mask.Enabled && !mask.Expired(),
+	}
+	// Email is kept for mail servers that don't know about multiple recipients yet.
+	if len(emails) > 0 {
+		response.Email = emails[0]
+	}
+	return response, nil
 }
 func (b *mainApiServiceImpl) IncrementForwardedCount(ctx context.Context, request *stubs.IncrementForwardedCountRequest) (*emptypb.Empty, error) {
 
@@ -89,7 +133,7 @@ func (b *mainApiServiceImpl) IncrementForwardedCount(ctx context.Context, reques
 		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
 	}
 
-	err := b.db.Table("masks").Where("mask = ?", request.MaskAddress).Updates(map[string]interface{}{"messages_received": gorm.Expr("messages_received + ?", 1), "messages_forwarded": gorm.Expr("messages_forwarded + ?", 1)}).Error
+	err := b.db.Table("masks").Where("mask = ?", request.MaskAddress).Updates(map[string]interface{}{"messages_received": gorm.Expr("messages_received + ?", 1), "messages_forwarded": gorm.Expr("messages_forwarded + ?", 1), "enabled": disableOnLimit}).Error
 
 	if err != nil {
 		logrus.Errorf("db error: %v", err)
@@ -109,7 +153,7 @@ func (b *mainApiServiceImpl) IncrementReceivedCount(ctx context.Context, request
 		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
 	}
 
-	err := b.db.Table("masks").Where("mask = ?", request.MaskAddress).UpdateColumn("messages_received", gorm.Expr("messages_received + ?", 1)).Error
+	err := b.db.Table("masks").Where("mask = ?", request.MaskAddress).UpdateColumns(map[string]interface{}{"messages_received": gorm.Expr("messages_received + ?", 1), "enabled": disableOnLimit}).Error
 	if err != nil {
 		logrus.Errorf("db error: %v", err)
 		return &empty.Empty{}, status.New(codes.Unavailable, err.Error()).Err()
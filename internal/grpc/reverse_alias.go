@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/maskrapp/api/internal/models"
+	stubs "github.com/maskrapp/api/internal/pb/main_api/v1"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxReverseAliasAttempts is the amount of aliases we try before giving up on finding an unused one.
+const maxReverseAliasAttempts = 5
+
+// CreateReverseAlias returns the reverse alias of a mask and external sender pair, a new one is minted if it doesn't exist yet.
+// The mail server uses it as the reply address of the messages it forwards.
+func (b *mainApiServiceImpl) CreateReverseAlias(ctx context.Context, request *stubs.CreateReverseAliasRequest) (*stubs.CreateReverseAliasResponse, error) {
+	split := strings.Split(request.MaskAddress, "@")
+	if len(split) != 2 {
+		return nil, status.New(codes.InvalidArgument, "invalid mask address").Err()
+	}
+
+	domain, err := b.domains.Get(split[1])
+	if err != nil {
+		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
+	}
+
+	sender := strings.ToLower(request.SenderAddress)
+	if !utils.EmailRegex.MatchString(sender) {
+		return nil, status.New(codes.InvalidArgument, "invalid sender address").Err()
+	}
+
+	maskAddress := request.MaskAddress
+	var result struct {
+		Found bool
+	}
+	err = b.db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ?) AS found",
+		maskAddress).Scan(&result).Error
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+	if !result.Found {
+		// The alias references the mask, so a catch-all mask that CheckMask accepted is created here when GetMask hasn't been called yet.
+		mask, err := b.catchAllMask(domain, maskAddress)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, status.New(codes.NotFound, "mask not found").Err()
+			}
+			logrus.Errorf("db error: %v", err)
+			return nil, status.New(codes.Unavailable, err.Error()).Err()
+		}
+		maskAddress = mask.Mask
+	}
+
+	for i := 0; i < maxReverseAliasAttempts; i++ {
+		existing := &models.ReverseAlias{}
+		err = b.db.First(existing, "mask_address = ? AND sender = ?", maskAddress, sender).Error
+		if err == nil {
+			return &stubs.CreateReverseAliasResponse{ReverseAlias: existing.Alias}, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.Errorf("db error: %v", err)
+			return nil, status.New(codes.Unavailable, err.Error()).Err()
+		}
+
+		token, err := utils.GenerateRandomString(16)
+		if err != nil {
+			logrus.Errorf("reverse alias generation error: %v", err)
+			return nil, status.New(codes.Internal, err.Error()).Err()
+		}
+		record := &models.ReverseAlias{
+			Alias:       utils.ReverseAliasPrefix + token + "@" + split[1],
+			MaskAddress: maskAddress,
+			Sender:      sender,
+		}
+		err = b.db.Create(record).Error
+		if err == nil {
+			return &stubs.CreateReverseAliasResponse{ReverseAlias: record.Alias}, nil
+		}
+		// Either the alias is taken, or a concurrent request created one for this pair. The next iteration handles both.
+		if !strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+			logrus.Errorf("db error: %v", err)
+			return nil, status.New(codes.Unavailable, err.Error()).Err()
+		}
+	}
+	return nil, status.New(codes.Unavailable, "could not create reverse alias").Err()
+}
+
+// ResolveReverseAlias resolves a reverse alias back to the mask and the external sender it was created for.
+// The from address has to be one of the mask owner's verified emails, so nobody else can send mail through the mask.
+func (b *mainApiServiceImpl) ResolveReverseAlias(ctx context.Context, request *stubs.ResolveReverseAliasRequest) (*stubs.ResolveReverseAliasResponse, error) {
+	split := strings.Split(request.ReverseAlias, "@")
+	if len(split) != 2 || !strings.HasPrefix(split[0], utils.ReverseAliasPrefix) {
+		return nil, status.New(codes.InvalidArgument, "invalid reverse alias").Err()
+	}
+
+	if _, err := b.domains.Get(split[1]); err != nil {
+		return nil, status.New(codes.InvalidArgument, "invalid reverse alias domain").Err()
+	}
+
+	var record struct {
+		MaskAddress string
+		Sender      string
+		UserID      string
+	}
+	err := b.db.Table("reverse_aliases").Select("reverse_aliases.mask_address, reverse_aliases.sender, masks.user_id").Joins("inner join masks on masks.mask = reverse_aliases.mask_address").Where("reverse_aliases.alias = ?", strings.ToLower(request.ReverseAlias)).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.New(codes.NotFound, "reverse alias not found").Err()
+		}
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+
+	var result struct {
+		Found bool
+	}
+	err = b.db.Raw("SELECT EXISTS(SELECT 1 FROM emails WHERE user_id = ? AND lower(email) = lower(?) AND is_verified) AS found",
+		record.UserID, request.FromAddress).Scan(&result).Error
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+	if !result.Found {
+		return nil, status.New(codes.PermissionDenied, "sender does not own the mask").Err()
+	}
+
+	return &stubs.ResolveReverseAliasResponse{
+		MaskAddress:   record.MaskAddress,
+		SenderAddress: record.Sender,
+	}, nil
+}
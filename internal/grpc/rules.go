@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/maskrapp/api/internal/models"
+	stubs "github.com/maskrapp/api/internal/pb/main_api/v1"
+	"github.com/maskrapp/api/internal/rules"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// CheckSender decides whether mail from the sender should be forwarded, dropped or rejected by the mail server.
+// Disabled masks drop everything, otherwise the sender rules of the mask decide. Mail is forwarded when no rule matches.
+func (b *mainApiServiceImpl) CheckSender(ctx context.Context, request *stubs.CheckSenderRequest) (*stubs.CheckSenderResponse, error) {
+	split := strings.Split(request.MaskAddress, "@")
+	if len(split) != 2 {
+		return nil, status.New(codes.InvalidArgument, "invalid mask address").Err()
+	}
+
+	domain, err := b.domains.Get(split[1])
+	if err != nil {
+		return nil, status.New(codes.InvalidArgument, "invalid mask domain").Err()
+	}
+
+	if !utils.EmailRegex.MatchString(request.SenderAddress) {
+		return nil, status.New(codes.InvalidArgument, "invalid sender address").Err()
+	}
+
+	mask := &models.Mask{}
+	err = b.db.Select("enabled, expires_at, max_received, messages_received").First(mask, "mask = ?", request.MaskAddress).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Catch-all masks don't exist before GetMask creates them, and have no rules yet. This matches the answer of CheckMask.
+		err = b.catchAllAllowed(domain, request.MaskAddress)
+		if err == nil {
+			return &stubs.CheckSenderResponse{Decision: stubs.SenderDecision_SENDER_DECISION_FORWARD}, nil
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.New(codes.NotFound, "mask not found").Err()
+		}
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+	if !mask.Enabled || mask.Expired() {
+		return &stubs.CheckSenderResponse{Decision: stubs.SenderDecision_SENDER_DECISION_DROP}, nil
+	}
+
+	maskRules := make([]*models.MaskRule, 0)
+	err = b.db.Find(&maskRules, "mask_address = ?", request.MaskAddress).Error
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return nil, status.New(codes.Unavailable, err.Error()).Err()
+	}
+
+	switch rules.Evaluate(maskRules, request.SenderAddress) {
+	case rules.ActionBlock:
+		return &stubs.CheckSenderResponse{Decision: stubs.SenderDecision_SENDER_DECISION_DROP}, nil
+	case rules.ActionReject:
+		return &stubs.CheckSenderResponse{Decision: stubs.SenderDecision_SENDER_DECISION_REJECT}, nil
+	}
+	return &stubs.CheckSenderResponse{Decision: stubs.SenderDecision_SENDER_DECISION_FORWARD}, nil
+}
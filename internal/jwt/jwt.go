@@ -19,52 +19,63 @@ type UserClaims struct {
 	Type     string `json:"type"` // 'refresh' for refresh tokens and 'access' for access tokens.
 	Version  int    `json:"version"`
 	Provider string `json:"provider"`
+	Session  string `json:"sid,omitempty"` // The server-side session the token belongs to, empty for mfa tokens.
+	// Generation counts the rotations of the refresh token of the session. Only the latest generation can be refreshed.
+	Generation int `json:"gen,omitempty"`
 	jwt.StandardClaims
 }
 
-// New creates a new JWTHandler instance.
-func New(secret string, atExpires, rtExpires time.Duration) *JWTHandler {
-	return &JWTHandler{secret: secret, atExpires: atExpires, rtExpires: rtExpires}
+// New creates a new JWTHandler instance. Tokens are signed with the active key of the rotation schedule,
+// or with the HS256 secret when there are no keys. The secret stays valid for verification while it is set.
+func New(secret string, keys []*Key, atExpires, rtExpires time.Duration) *JWTHandler {
+	return &JWTHandler{secret: secret, keys: sortKeys(keys), atExpires: atExpires, rtExpires: rtExpires}
 }
 
 type JWTHandler struct {
 	secret    string
+	keys      []*Key
 	atExpires time.Duration
 	rtExpires time.Duration
 }
 
-func (j *JWTHandler) GenerateAccessToken(id string, version int, provider string) (Token, error) {
+// AccessTokenExpiry returns how long access tokens are valid for.
+func (j *JWTHandler) AccessTokenExpiry() time.Duration {
+	return j.atExpires
+}
+
+func (j *JWTHandler) GenerateAccessToken(id string, version int, provider, session string) (Token, error) {
 	expiresAt := time.Now().Add(j.atExpires).Unix()
 	claims := UserClaims{
 		UserId:   id,
 		Version:  version,
 		Type:     "access",
 		Provider: provider,
+		Session:  session,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expiresAt,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(j.secret))
+	t, err := j.sign(claims)
 	if err != nil {
 		return Token{}, err
 	}
 	return Token{Token: t, ExpiresAt: claims.ExpiresAt, Provider: provider}, nil
 }
-func (j *JWTHandler) GenerateRefreshToken(id string, version int, provider string) (Token, error) {
+func (j *JWTHandler) GenerateRefreshToken(id string, version int, provider, session string, generation int) (Token, error) {
 	expiresAt := time.Now().Add(j.rtExpires).Unix()
 	claims := UserClaims{
-		UserId:   id,
-		Version:  version,
-		Type:     "refresh",
-		Provider: provider,
+		UserId:     id,
+		Version:    version,
+		Type:       "refresh",
+		Provider:   provider,
+		Session:    session,
+		Generation: generation,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expiresAt,
 			Subject:   id,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	t, err := token.SignedString([]byte(j.secret))
+	t, err := j.sign(claims)
 	if err != nil {
 		return Token{}, err
 	}
@@ -72,9 +83,7 @@ func (j *JWTHandler) GenerateRefreshToken(id string, version int, provider strin
 }
 
 func (j *JWTHandler) Validate(tokenString string, isRefresh bool) (*UserClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(j.secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, j.keyFunc)
 	if err != nil {
 		return &UserClaims{}, err
 	}
@@ -92,12 +101,13 @@ type Pair struct {
 	RefreshToken Token `json:"refresh_token"`
 }
 
-func (j *JWTHandler) CreatePair(userID string, version int, provider string) (*Pair, error) {
-	refreshToken, err := j.GenerateRefreshToken(userID, version, provider)
+// CreatePair creates the access and refresh token of a session, see sessions.Sessions for creating the session itself.
+func (j *JWTHandler) CreatePair(userID string, version int, provider, session string, generation int) (*Pair, error) {
+	refreshToken, err := j.GenerateRefreshToken(userID, version, provider, session, generation)
 	if err != nil {
 		return nil, err
 	}
-	accessToken, err := j.GenerateAccessToken(userID, version, provider)
+	accessToken, err := j.GenerateAccessToken(userID, version, provider, session)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +117,41 @@ func (j *JWTHandler) CreatePair(userID string, version int, provider string) (*P
 	}, nil
 }
 
+// GenerateMFAToken generates a short-lived token for a user that still has to complete their second sign-in step.
+// The token can't be used as an access or refresh token.
+func (j *JWTHandler) GenerateMFAToken(id string, version int, provider string) (Token, error) {
+	expiresAt := time.Now().Add(5 * time.Minute).Unix()
+	claims := UserClaims{
+		UserId:   id,
+		Version:  version,
+		Type:     "mfa",
+		Provider: provider,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt,
+			Subject:   id,
+		},
+	}
+	t, err := j.sign(claims)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Token: t, ExpiresAt: claims.ExpiresAt, Provider: provider}, nil
+}
+
+func (j *JWTHandler) ValidateMFAToken(tokenString string) (*UserClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, j.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
+		if claims.Type != "mfa" {
+			return nil, errors.New("token type mismatch")
+		}
+		return claims, nil
+	}
+	return nil, err
+}
+
 type PasswordResetTokenClaims struct {
 	UserId  string `json:"user_id"`
 	Version int    `json:"version"`
@@ -123,8 +168,7 @@ func (j *JWTHandler) GeneratePasswordResetToken(userId string, version int) (str
 			Subject:   userId,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(j.secret))
+	tokenString, err := j.sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -132,9 +176,7 @@ func (j *JWTHandler) GeneratePasswordResetToken(userId string, version int) (str
 }
 
 func (j *JWTHandler) ValidatePasswordResetToken(tokenString string) (*PasswordResetTokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &PasswordResetTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(j.secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &PasswordResetTokenClaims{}, j.keyFunc)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,85 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt"
+	"github.com/maskrapp/api/internal/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKeys(t *testing.T) []*jwt.Key {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	return []*jwt.Key{
+		{ID: "next", Method: gojwt.SigningMethodEdDSA, Private: edPrivate, Public: edPublic, ActiveFrom: time.Now().Add(time.Hour)},
+		{ID: "current", Method: gojwt.SigningMethodRS256, Private: rsaKey, Public: &rsaKey.PublicKey, ActiveFrom: time.Now().Add(-time.Hour)},
+	}
+}
+
+func kid(t *testing.T, token string) string {
+	parsed, _, err := new(gojwt.Parser).ParseUnverified(token, &jwt.UserClaims{})
+	assert.Nil(t, err)
+	id, _ := parsed.Header["kid"].(string)
+	return id
+}
+
+func TestRotation(t *testing.T) {
+	keys := newKeys(t)
+	handler := jwt.New("", keys, time.Minute, time.Hour)
+
+	pair, err := handler.CreatePair("user", 1, "email", "session", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "current", kid(t, pair.AccessToken.Token), "the key whose time has come signs")
+
+	claims, err := handler.Validate(pair.AccessToken.Token, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "user", claims.UserId)
+
+	// Once the next key is active, tokens of the previous key stay valid.
+	keys[0].ActiveFrom = time.Now().Add(-time.Minute)
+	rotated := jwt.New("", keys, time.Minute, time.Hour)
+	next, err := rotated.GenerateAccessToken("user", 1, "email", "session")
+	assert.Nil(t, err)
+	assert.Equal(t, "next", kid(t, next.Token))
+	_, err = rotated.Validate(pair.AccessToken.Token, false)
+	assert.Nil(t, err)
+
+	jwks := rotated.JWKS()
+	assert.Len(t, jwks, 2)
+	assert.Equal(t, "RSA", jwks[0].KeyType)
+	assert.Equal(t, "OKP", jwks[1].KeyType)
+}
+
+func TestLegacySecret(t *testing.T) {
+	legacy := jwt.New("secret", nil, time.Minute, time.Hour)
+	token, err := legacy.GenerateAccessToken("user", 1, "email", "session")
+	assert.Nil(t, err)
+
+	handler := jwt.New("secret", newKeys(t), time.Minute, time.Hour)
+	_, err = handler.Validate(token.Token, false)
+	assert.Nil(t, err, "HS256 tokens are accepted while the secret is set")
+
+	withoutSecret := jwt.New("", newKeys(t), time.Minute, time.Hour)
+	_, err = withoutSecret.Validate(token.Token, false)
+	assert.NotNil(t, err)
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	keys := newKeys(t)
+	handler := jwt.New("", keys, time.Minute, time.Hour)
+
+	// A token that claims the kid of an RSA key, but is signed with HMAC.
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, jwt.UserClaims{UserId: "user", Type: "access"})
+	token.Header["kid"] = "current"
+	forged, err := token.SignedString([]byte("guess"))
+	assert.Nil(t, err)
+	_, err = handler.Validate(forged, false)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is an asymmetric signing key. It becomes the signing key at ActiveFrom, until the next key of the schedule becomes active.
+// Every configured key is used for verification, so retired keys keep verifying the tokens they signed.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	Private    crypto.PrivateKey
+	Public     crypto.PublicKey
+	ActiveFrom time.Time
+}
+
+// JWK is the public part of a key, as described in RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// ParseKeys parses a comma separated list of keys in the format `kid=path[@activeFrom]`. The path points to a PEM encoded RSA or Ed25519 private key,
+// and activeFrom is an RFC 3339 time. Keys without a time are active from the start.
+func ParseKeys(spec string) ([]*Key, error) {
+	keys := make([]*Key, 0)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, rest, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("invalid key entry: %v", entry)
+		}
+		path, activeFrom, hasTime := strings.Cut(rest, "@")
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKey(kid, pem)
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", kid, err)
+		}
+		if hasTime {
+			key.ActiveFrom, err = time.Parse(time.RFC3339, activeFrom)
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", kid, err)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseKey parses a PEM encoded RSA or Ed25519 private key. RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func ParseKey(kid string, pem []byte) (*Key, error) {
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: rsaKey, Public: &rsaKey.PublicKey}, nil
+	}
+	edKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, errors.New("expected a PEM encoded RSA or Ed25519 private key")
+	}
+	private, ok := edKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("expected a PEM encoded RSA or Ed25519 private key")
+	}
+	return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, Private: private, Public: private.Public()}, nil
+}
+
+func sortKeys(keys []*Key) []*Key {
+	sorted := append([]*Key{}, keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom)
+	})
+	return sorted
+}
+
+// signingKey returns the key that is active at the given time, or nil when no key is active yet.
+func (j *JWTHandler) signingKey(now time.Time) *Key {
+	var active *Key
+	for _, key := range j.keys {
+		if key.ActiveFrom.After(now) {
+			break
+		}
+		active = key
+	}
+	return active
+}
+
+func (j *JWTHandler) sign(claims jwt.Claims) (string, error) {
+	key := j.signingKey(time.Now())
+	if key == nil {
+		if j.secret == "" {
+			return "", errors.New("no signing key is active")
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(j.secret))
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// keyFunc returns the verification key of a token. The algorithm of the token has to match its key, so that a public key
+// can't be used as an HMAC secret.
+func (j *JWTHandler) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, ok := t.Header["kid"].(string)
+	if !ok {
+		if j.secret != "" && t.Method == jwt.SigningMethodHS256 {
+			return []byte(j.secret), nil
+		}
+		return nil, ErrUnknownKey
+	}
+	for _, key := range j.keys {
+		if key.ID == kid {
+			if t.Method.Alg() != key.Method.Alg() {
+				return nil, ErrUnknownKey
+			}
+			return key.Public, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKS returns the public keys that verify tokens, including keys that become active later so that they can be fetched ahead of time.
+func (j *JWTHandler) JWKS() []JWK {
+	keys := make([]JWK, 0, len(j.keys))
+	for _, key := range j.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
@@ -10,29 +10,37 @@ import (
 )
 
 type Mailer struct {
-	httpClient   *req.Client
-	token        string
-	templateKey  string
-	emailAddress string
-	production   bool
+	httpClient                     *req.Client
+	token                          string
+	templateKey                    string
+	magicLinkTemplateKey           string
+	accountDeletedTemplateKey      string
+	primaryEmailChangedTemplateKey string
+	exportTemplateKey              string
+	emailAddress                   string
+	production                     bool
 }
 
 func New(config *config.Config) *Mailer {
 	httpClient := req.C()
 	return &Mailer{
-		httpClient:   httpClient,
-		token:        config.ZeptoMail.EmailToken,
-		templateKey:  config.ZeptoMail.TemplateKey,
-		emailAddress: config.ZeptoMail.EmailAddress,
-		production:   config.Production,
+		httpClient:                     httpClient,
+		token:                          config.ZeptoMail.EmailToken,
+		templateKey:                    config.ZeptoMail.TemplateKey,
+		magicLinkTemplateKey:           config.ZeptoMail.MagicLinkTemplateKey,
+		accountDeletedTemplateKey:      config.ZeptoMail.AccountDeletedTemplateKey,
+		primaryEmailChangedTemplateKey: config.ZeptoMail.PrimaryEmailChangedTemplateKey,
+		exportTemplateKey:              config.ZeptoMail.ExportTemplateKey,
+		emailAddress:                   config.ZeptoMail.EmailAddress,
+		production:                     config.Production,
 	}
 }
 
-func (m *Mailer) createJSON(email, code string) ([]byte, error) {
+func (m *Mailer) createTemplateJSON(email, templateKey string, mergeInfo map[string]string) ([]byte, error) {
 	// https://www.zoho.com/zeptomail/help/api/email-templates.html
 
 	reqMap := make(map[string]interface{})
-	reqMap["mail_template_key"] = m.templateKey
+	reqMap["mail_template_key"] = templateKey
 	reqMap["bounce_address"] = "bounce@bounce.maskr.org"
 	reqMap["from"] = map[string]string{
 		"address": m.emailAddress,
@@ -50,16 +58,13 @@ func (m *Mailer) createJSON(email, code string) ([]byte, error) {
 
 	reqMap["to"] = recipients
 
-	reqMap["merge_info"] = map[string]string{
-		"code": code,
-	}
+	reqMap["merge_info"] = mergeInfo
 	return json.Marshal(reqMap)
 }
 
-// SendVerifyEmail is used when a user adds a new email to their account.
-func (m *Mailer) SendVerifyMail(email, code string) error {
-
-	data, err := m.createJSON(email, code)
+// send sends the template with the given key to the email, the merge info fills in the placeholders of the template.
+func (m *Mailer) send(email, templateKey string, mergeInfo map[string]string) error {
+	data, err := m.createTemplateJSON(email, templateKey, mergeInfo)
 	if err != nil {
 		return err
 	}
@@ -90,73 +95,59 @@ func (m *Mailer) SendVerifyMail(email, code string) error {
 	return nil
 }
 
+// SendVerifyEmail is used when a user adds a new email to their account.
+func (m *Mailer) SendVerifyMail(email, code string) error {
+	return m.send(email, m.templateKey, map[string]string{
+		"code": code,
+	})
+}
+
 // SendUserVerificationMail is used when a user creates their account.
 func (m *Mailer) SendUserVerificationMail(email, code string) error {
 
 	//TODO: use different template
 
-	data, err := m.createJSON(email, code)
-	if err != nil {
-		return err
-	}
-	var responseData map[string]interface{}
-	headers := map[string]string{
-		"Accept":        "application/json",
-		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Zoho-enczapikey %v", m.token),
-	}
-
-	resp, err := m.httpClient.R().SetBody(data).
-		SetRetryCount(3).
-		SetRetryFixedInterval(500 * time.Millisecond).
-		SetRetryCondition(func(resp *req.Response, err error) bool {
-			return !resp.IsSuccess()
-		}).
-		SetHeaders(headers).
-		SetSuccessResult(responseData).
-		SetErrorResult(responseData).
-		Post("https://api.zeptomail.eu/v1.1/email/template")
-
-	if err != nil {
-		return err
-	}
-	if !resp.IsSuccess() {
-		return fmt.Errorf("expected status code 201, got: %v with response body: %v", resp.StatusCode, responseData)
-	}
-	return err
+	return m.send(email, m.templateKey, map[string]string{
+		"code": code,
+	})
 }
 
 func (m *Mailer) SendPasswordCodeMail(email, code string) error {
 
 	//TODO: use different template
 
-	data, err := m.createJSON(email, code)
-	if err != nil {
-		return err
-	}
-	var responseData map[string]interface{}
-	headers := map[string]string{
-		"Accept":        "application/json",
-		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Zoho-enczapikey %v", m.token),
-	}
+	return m.send(email, m.templateKey, map[string]string{
+		"code": code,
+	})
+}
 
-	resp, err := m.httpClient.R().SetBody(data).
-		SetRetryCount(3).
-		SetRetryFixedInterval(500 * time.Millisecond).
-		SetRetryCondition(func(resp *req.Response, err error) bool {
-			return !resp.IsSuccess()
-		}).
-		SetHeaders(headers).
-		SetSuccessResult(responseData).
-		SetErrorResult(responseData).
-		Post("https://api.zeptomail.eu/v1.1/email/template")
+// SendMagicLinkMail is used when a user signs in without a password. The mail contains both a sign in link and a code.
+func (m *Mailer) SendMagicLinkMail(email, code, link string) error {
+	return m.send(email, m.magicLinkTemplateKey, map[string]string{
+		"code": code,
+		"link": link,
+	})
+}
 
-	if err != nil {
-		return err
-	}
-	if !resp.IsSuccess() {
-		return fmt.Errorf("expected status code 201, got: %v with response body: %v", resp.StatusCode, responseData)
-	}
-	return err
+// SendAccountDeletedMail is used for confirming that an account and its data have been deleted.
+func (m *Mailer) SendAccountDeletedMail(email string) error {
+	return m.send(email, m.accountDeletedTemplateKey, map[string]string{
+		"event": "account_deleted",
+	})
+}
+
+// SendExportMail is used when a data export that was prepared in the background is ready for download.
+func (m *Mailer) SendExportMail(email, link string) error {
+	return m.send(email, m.exportTemplateKey, map[string]string{
+		"event": "export_ready",
+		"link":  link,
+	})
+}
+
+// SendPrimaryEmailChangedMail is used for notifying both the previous and the new primary email of an account about the change.
+func (m *Mailer) SendPrimaryEmailChangedMail(email, primaryEmail string) error {
+	return m.send(email, m.primaryEmailChangedTemplateKey, map[string]string{
+		"event":         "primary_email_changed",
+		"primary_email": primaryEmail,
+	})
 }
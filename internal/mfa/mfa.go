@@ -0,0 +1,31 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/totp"
+	"gorm.io/gorm"
+)
+
+// Enabled reports whether the user has to complete a second step when signing in.
+func Enabled(user *models.User) bool {
+	return user.TOTPEnabled
+}
+
+// VerifyTOTP checks a code of the confirmed authenticator of the user. A code is only accepted once.
+func VerifyTOTP(db *gorm.DB, user *models.User, code string) (bool, error) {
+	if !user.TOTPEnabled || user.TOTPSecret == "" {
+		return false, nil
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// The step is only moved forward, concurrent requests with the same code can't both succeed.
+	res := db.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,71 @@
+package mfa
+
+import (
+	"strings"
+	"time"
+
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// RecoveryCodeCount is the amount of recovery codes that are generated at once.
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// GenerateRecoveryCodes replaces the recovery codes of the user with a new set. The plain codes are only returned here.
+func GenerateRecoveryCodes(db *gorm.DB, userID string) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]*models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := utils.GenerateRandomString(recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		code = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hash, err := utils.HashCode(code)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, &models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&models.RecoveryCode{}, "user_id = ?", userID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyRecoveryCode checks the code against the unused recovery codes of the user, and marks the matching code as used.
+func VerifyRecoveryCode(db *gorm.DB, userID, code string) (bool, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != recoveryCodeLength+1 {
+		return false, nil
+	}
+	records := make([]*models.RecoveryCode, 0)
+	err := db.Find(&records, "user_id = ? AND used_at = 0", userID).Error
+	if err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if !utils.CompareHash(code, record.CodeHash) {
+			continue
+		}
+		// The code is only consumed by the request that marks it as used.
+		res := db.Model(&models.RecoveryCode{}).Where("id = ? AND used_at = 0", record.ID).Update("used_at", time.Now().Unix())
+		if res.Error != nil {
+			return false, res.Error
+		}
+		return res.RowsAffected == 1, nil
+	}
+	return false, nil
+}
@@ -1,13 +1,22 @@
 package middleware
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/go-redis/redis/v9"
 	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/apikeys"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
 )
 
+// providerAPIKey is stored as the provider of requests that are authenticated with an API key.
+const providerAPIKey = "api_key"
+
+// AuthMiddleware authenticates requests with either an access token or an API key.
+// Requests with an API key are limited to the scopes of the key, see Scopes.
 func AuthMiddleware(ctx global.Context) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		auth := c.GetReqHeaders()["Authorization"]
@@ -22,6 +31,9 @@ func AuthMiddleware(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 		accessToken := split[1]
+		if apikeys.IsKey(accessToken) {
+			return authenticateKey(ctx, c, accessToken)
+		}
 		claims, err := ctx.Instances().JWT.Validate(accessToken, false)
 		if err != nil {
 			if strings.Contains(err.Error(), "token mismatch") {
@@ -41,7 +53,82 @@ func AuthMiddleware(ctx global.Context) func(*fiber.Ctx) error {
 			})
 
 		}
+		// Deleted accounts are blacklisted for as long as their access tokens are valid.
+		key := fmt.Sprintf("user-blacklist:%v", claims.UserId)
+		err = ctx.Instances().Redis.Get(c.Context(), key).Err()
+		if err == nil {
+			return c.Status(401).JSON(&models.APIResponse{
+				Success: false,
+				Message: "The user that is associated with your token no longer exists",
+			})
+		}
+		if err != redis.Nil {
+			logrus.Errorf("redis error: %v", err)
+		}
 		c.Locals("user_id", claims.UserId)
+		c.Locals("provider", claims.Provider)
+		c.Locals("session_id", claims.Session)
+		return c.Next()
+	}
+}
+
+func authenticateKey(ctx global.Context, c *fiber.Ctx, key string) error {
+	record, err := apikeys.Authenticate(ctx.Instances().Gorm, key)
+	if err != nil {
+		if err == apikeys.ErrInvalid {
+			return c.Status(401).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid API key",
+			})
+		}
+		if err == apikeys.ErrExpired {
+			return c.Status(401).JSON(&models.APIResponse{
+				Success: false,
+				Message: "API key is expired",
+			})
+		}
+		logrus.Errorf("db error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	c.Locals("user_id", record.UserID)
+	c.Locals("provider", providerAPIKey)
+	c.Locals("scopes", record.Scopes)
+	return c.Next()
+}
+
+// Scopes limits API keys to the route group, requests that only read need the read scope and every other request needs the write scope.
+// Access tokens aren't limited. It has to be used after AuthMiddleware.
+func Scopes(read, write string) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		granted, ok := c.Locals("scopes").([]string)
+		if !ok {
+			return c.Next()
+		}
+		scope := write
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			scope = read
+		}
+		if !apikeys.HasScope(granted, scope) {
+			return c.Status(403).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Your API key is missing the " + scope + " scope",
+			})
+		}
 		return c.Next()
 	}
 }
+
+// NoAPIKeys refuses requests that are authenticated with an API key, for routes that manage the account itself.
+// It has to be used after AuthMiddleware.
+func NoAPIKeys(c *fiber.Ctx) error {
+	if _, ok := c.Locals("scopes").([]string); ok {
+		return c.Status(403).JSON(&models.APIResponse{
+			Success: false,
+			Message: "API keys can't be used here",
+		})
+	}
+	return c.Next()
+}
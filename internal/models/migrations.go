@@ -0,0 +1,53 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultPlans are created when they don't exist yet. Changes made to them in the database are kept.
+var DefaultPlans = []*Plan{
+	{ID: "free", Name: "Free", MaxMasks: 25, MaxEmails: 2, MaxCustomDomains: 0, PremiumDomains: false},
+	{ID: "premium", Name: "Premium", MaxMasks: Unlimited, MaxEmails: 10, MaxCustomDomains: 10, PremiumDomains: true},
+}
+
+// Migrate updates the database schema and moves existing data over to it.
+func Migrate(db *gorm.DB) error {
+	// Users reference the default plan, so it has to exist before the users table is migrated.
+	err := db.AutoMigrate(&Plan{})
+	if err != nil {
+		return err
+	}
+	err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(DefaultPlans).Error
+	if err != nil {
+		return err
+	}
+	err = db.AutoMigrate(&User{}, Email{}, EmailVerification{}, Mask{}, MaskRecipient{}, Provider{}, AccountVerification{}, Domain{}, DomainClaim{}, PasswordResetVerification{}, ReverseAlias{}, MaskRule{}, RecoveryCode{}, WebAuthnCredential{}, MagicLinkVerification{}, Session{}, SecurityEvent{}, APIKey{}, ReservedMask{})
+	if err != nil {
+		return err
+	}
+	err = migrateMaskRecipients(db)
+	if err != nil {
+		return err
+	}
+	return migrateProviderIDs(db)
+}
+
+// migrateMaskRecipients moves the single `forward_to` email of older masks into the mask_recipients table.
+func migrateMaskRecipients(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Mask{}, "forward_to") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO mask_recipients (mask_address, email_id, created_at) SELECT mask, forward_to, NOW() FROM masks WHERE forward_to IS NOT NULL ON CONFLICT DO NOTHING").Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&Mask{}, "forward_to")
+	})
+}
+
+// migrateProviderIDs prefixes the ids of external providers with the provider name, see ProviderID.
+func migrateProviderIDs(db *gorm.DB) error {
+	return db.Exec("UPDATE providers SET id = provider_name || ':' || id WHERE provider_name <> 'email' AND id NOT LIKE provider_name || ':%'").Error
+}
@@ -2,6 +2,11 @@ package models
 
 import "time"
 
+// ProviderID builds the id of an external provider record, the subject alone isn't unique across providers.
+func ProviderID(providerName, subject string) string {
+	return providerName + ":" + subject
+}
+
 type Provider struct {
 	ID           string `json:"id" gorm:"primaryKey"`
 	ProviderName string `json:"provider_name" gorm:"not null"`
@@ -12,19 +17,116 @@ type Provider struct {
 }
 
 type User struct {
-	ID           string    `json:"id" gorm:"primaryKey"`
-	Role         int       `json:"role" gorm:"not null"`
-	Password     string    `json:"-"`
-	Email        string    `json:"email" gorm:"not null"`
-	TokenVersion int       `json:"-" gorm:"default:1"`
-	CreatedAt    time.Time `json:"-"`
-	UpdatedAt    time.Time `json:"-"`
+	ID           string `json:"id" gorm:"primaryKey"`
+	Role         int    `json:"role" gorm:"not null"`
+	Plan         *Plan  `json:"-"`
+	PlanID       string `json:"plan_id" gorm:"not null;default:free"`
+	Password     string `json:"-"`
+	Email        string `json:"email" gorm:"not null"`
+	TokenVersion int    `json:"-" gorm:"default:1"`
+	// TOTPSecret is the secret of the confirmed authenticator, TOTPPendingSecret holds the secret of an enrollment that hasn't been confirmed yet.
+	TOTPSecret        string    `json:"-"`
+	TOTPPendingSecret string    `json:"-"`
+	TOTPEnabled       bool      `json:"-"`
+	TOTPLastStep      int64     `json:"-"` // The time step of the last accepted code, used to refuse codes that have already been used.
+	CreatedAt         time.Time `json:"-"`
+	UpdatedAt         time.Time `json:"-"`
+}
+
+// Unlimited disables a limit of a plan.
+const Unlimited = -1
+
+// Plan holds the limits of the users that are on it. Besides the default plans, plans with custom limits can be created for individual users.
+type Plan struct {
+	ID               string    `json:"id" gorm:"primaryKey"`
+	Name             string    `json:"name" gorm:"not null"`
+	MaxMasks         int       `json:"max_masks" gorm:"not null"`
+	MaxEmails        int       `json:"max_emails" gorm:"not null"`
+	MaxCustomDomains int       `json:"max_custom_domains" gorm:"not null"`
+	PremiumDomains   bool      `json:"premium_domains"` // Whether domains that aren't free can be used.
+	CreatedAt        time.Time `json:"-"`
+	UpdatedAt        time.Time `json:"-"`
+}
+
+// MagicLinkVerification holds the hashes of the link token and code that were mailed for a passwordless sign in. Either can be redeemed once.
+type MagicLinkVerification struct {
+	ID        int    `gorm:"primaryKey"`
+	User      User   `gorm:"constraint:OnDelete:CASCADE;"`
+	UserID    string `gorm:"uniqueIndex"`
+	TokenHash string
+	CodeHash  string
+	ExpiresAt int64
+	CreatedAt time.Time `json:"-"`
+}
+
+// Session is a device that signed in. Refresh tokens can only be used as long as their session exists.
+type Session struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	User       User      `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID     string    `json:"-" gorm:"index"`
+	Provider   string    `json:"provider"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastUsedAt int64     `json:"last_used_at"`
+	Generation int       `json:"-"` // The generation of the latest refresh token, see jwt.UserClaims.
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SecurityEvent records suspicious activity on an account, like the reuse of a rotated refresh token.
+type SecurityEvent struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	User      User      `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID    string    `json:"-" gorm:"index"`
+	Type      string    `json:"type"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey is a personal key that is used in place of an access token, for automation. Only the hash of the key is stored.
+type APIKey struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	User       User      `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID     string    `json:"-" gorm:"index"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"` // The start of the key, shown so that users can tell their keys apart.
+	KeyHash    string    `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     []string  `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  int64     `json:"expires_at"` // Unix timestamp, 0 means the key never expires.
+	LastUsedAt int64     `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RecoveryCode can be used once in place of a second factor. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	User      User      `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID    string    `json:"-" gorm:"index"`
+	CodeHash  string    `json:"-" gorm:"not null"`
+	UsedAt    int64     `json:"used_at"` // 0 when the code hasn't been used yet.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebAuthnCredential is a passkey that the user registered for signing in.
+type WebAuthnCredential struct {
+	ID         string    `json:"id" gorm:"primaryKey"` // Base64url encoded credential id.
+	User       User      `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID     string    `json:"-" gorm:"index"`
+	Name       string    `json:"name"`
+	PublicKey  []byte    `json:"-" gorm:"not null"`
+	Algorithm  int       `json:"-"`
+	SignCount  int64     `json:"-"`
+	LastUsedAt int64     `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type AccountVerification struct {
 	Email            string `gorm:"primaryKey"`
 	VerificationCode string
 	ExpiresAt        int64
+	FailedAttempts   int       `json:"-" gorm:"default:0"` // Wrong codes since the last lock, see verification.RecordFailure.
+	LockedUntil      int64     `json:"-" gorm:"default:0"`
 	CreatedAt        time.Time `json:"-"`
 	UpdatedAt        time.Time `json:"-"`
 }
@@ -46,6 +148,8 @@ type EmailVerification struct {
 	EmailID          int    `json:"email_id"`
 	VerificationCode string `json:"verification_code"`
 	ExpiresAt        int64  `json:"expires_at"`
+	FailedAttempts   int    `json:"-" gorm:"default:0"`
+	LockedUntil      int64  `json:"-" gorm:"default:0"`
 }
 
 type PasswordResetVerification struct {
@@ -54,28 +158,102 @@ type PasswordResetVerification struct {
 	UserID           string
 	VerificationCode string
 	ExpiresAt        int64
+	FailedAttempts   int       `json:"-" gorm:"default:0"`
+	LockedUntil      int64     `json:"-" gorm:"default:0"`
 	CreatedAt        time.Time `json:"-"`
 	UpdatedAt        time.Time `json:"-"`
 }
 
 type Mask struct {
-	Mask              string `json:"mask" gorm:"primaryKey"`
-	Enabled           bool   `json:"enabled"`
-	Email             Email  `gorm:"foreignKey:ForwardTo"`
-	ForwardTo         int    `json:"forward_to"`
-	User              User
-	UserID            string    `json:"user_id"`
-	MessagesReceived  int       `json:"messages_received" gorm:"default:0"`
-	MessagesForwarded int       `json:"messages_forwarded" gorm:"default:0"`
+	Mask              string          `json:"mask" gorm:"primaryKey"`
+	Enabled           bool            `json:"enabled"`
+	Recipients        []MaskRecipient `json:"-" gorm:"foreignKey:MaskAddress;constraint:OnDelete:CASCADE"`
+	User              User            `json:"-"`
+	UserID            string          `json:"user_id"`
+	MessagesReceived  int             `json:"messages_received" gorm:"default:0"`
+	MessagesForwarded int             `json:"messages_forwarded" gorm:"default:0"`
+	Label             string          `json:"label"`
+	Note              string          `json:"note"`
+	UsedOn            string          `json:"used_on" gorm:"index"`          // Normalized hostname of the website the mask is used on.
+	ExpiresAt         int64           `json:"expires_at" gorm:"default:0"`   // Unix timestamp, 0 means the mask never expires.
+	MaxReceived       int             `json:"max_received" gorm:"default:0"` // 0 means there is no limit.
+	DeleteOnExpiry    bool            `json:"delete_on_expiry"`
+	CreatedAt         time.Time       `json:"-"`
+	UpdatedAt         time.Time       `json:"-"`
+}
+
+// Expired reports whether the mask has passed its expiry time or has received its maximum amount of messages.
+func (m *Mask) Expired() bool {
+	if m.ExpiresAt > 0 && m.ExpiresAt <= time.Now().Unix() {
+		return true
+	}
+	return m.MaxReceived > 0 && m.MessagesReceived >= m.MaxReceived
+}
+
+// MaskRecipient links a mask to one of the emails it forwards to.
+type MaskRecipient struct {
+	MaskAddress string    `json:"mask" gorm:"primaryKey"`
+	Email       Email     `json:"-"`
+	EmailID     int       `json:"email_id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+// MaskRule decides what happens to mail that a mask receives from matching senders.
+type MaskRule struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	Mask        Mask      `json:"-" gorm:"foreignKey:MaskAddress;constraint:OnDelete:CASCADE"`
+	MaskAddress string    `json:"mask" gorm:"not null;index"`
+	Type        string    `json:"type" gorm:"not null"`
+	Pattern     string    `json:"pattern" gorm:"not null"`
+	Action      string    `json:"action" gorm:"not null"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// ReservedMask is the address of a mask that belonged to a deleted account. It can't be registered again by anyone.
+type ReservedMask struct {
+	Mask      string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// ReverseAlias is used for replying to an external sender through a mask, without revealing the user's real address.
+type ReverseAlias struct {
+	Alias       string    `json:"alias" gorm:"primaryKey"`
+	Mask        Mask      `json:"-" gorm:"foreignKey:MaskAddress;constraint:OnDelete:CASCADE"`
+	MaskAddress string    `json:"mask" gorm:"not null;uniqueIndex:idx_reverse_alias_sender"`
+	Sender      string    `json:"sender" gorm:"not null;uniqueIndex:idx_reverse_alias_sender"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// Domain is either a domain of the service, or a custom domain when UserID is set.
+// Custom domains are only stored once their ownership has been verified, until then they are a DomainClaim.
+type Domain struct {
+	Domain            string    `json:"domain" gorm:"primaryKey"`
+	Free              bool      `json:"free"`
+	User              *User     `json:"-"`
+	UserID            *string   `json:"user_id,omitempty" gorm:"index"`
+	Verified          bool      `json:"-"`
+	VerificationToken string    `json:"-"`
+	MXValid           bool      `json:"-"`
+	SPFValid          bool      `json:"-"`
+	DKIMValid         bool      `json:"-"`
+	CheckedAt         int64     `json:"-"`
+	CatchAll          bool      `json:"-"` // Creates masks for unknown addresses on the domain when they first receive mail.
+	CatchAllEmail     *Email    `json:"-" gorm:"foreignKey:CatchAllEmailID;constraint:OnDelete:SET NULL"`
+	CatchAllEmailID   *int      `json:"-"`
 	CreatedAt         time.Time `json:"-"`
 	UpdatedAt         time.Time `json:"-"`
 }
 
-type Domain struct {
-	Domain    string    `json:"domain" gorm:"primaryKey"`
-	Free      bool      `json:"free"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+// DomainClaim is a custom domain that a user added but hasn't verified yet.
+// Several users can claim the same domain, the first one to verify the ownership record gets the Domain.
+type DomainClaim struct {
+	Domain            string `gorm:"primaryKey"`
+	User              User   `gorm:"constraint:OnDelete:CASCADE;"`
+	UserID            string `gorm:"primaryKey"`
+	VerificationToken string
+	CreatedAt         time.Time
 }
 
 type APIResponse struct {
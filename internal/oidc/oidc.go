@@ -0,0 +1,223 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maskrapp/api/internal/config"
+	"golang.org/x/oauth2"
+)
+
+var ErrMissingSubject = errors.New("userinfo response doesn't contain a subject")
+
+// Identity is the user that signed in at a provider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider exchanges authorization codes of a single OpenID Connect or OAuth 2.0 provider.
+type Provider struct {
+	Name       string
+	cfg        config.OIDCProvider
+	httpClient *http.Client
+
+	mutex       sync.Mutex
+	oauth       *oauth2.Config
+	userInfoURL string
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry creates a provider for every provider in the config.
+func NewRegistry(cfg *config.Config, httpClient *http.Client) *Registry {
+	registry := &Registry{providers: make(map[string]*Provider)}
+	for _, providerCfg := range cfg.OIDC.Providers {
+		registry.providers[providerCfg.Name] = NewProvider(providerCfg, httpClient)
+	}
+	return registry
+}
+
+// NewProvider creates a new Provider instance. The endpoints are discovered on first use.
+func NewProvider(cfg config.OIDCProvider, httpClient *http.Client) *Provider {
+	return &Provider{
+		Name:       cfg.Name,
+		cfg:        cfg,
+		httpClient: httpClient,
+	}
+}
+
+// Get retrieves the provider with the given name.
+func (r *Registry) Get(name string) (*Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names returns the names of the configured providers.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Exchange exchanges the authorization code and retrieves the identity of the user from the userinfo endpoint.
+func (p *Provider) Exchange(ctx context.Context, code string) (*Identity, error) {
+	oauthConfig, userInfoURL, err := p.endpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	token.SetAuthHeader(req)
+	req.Header.Set("Accept", "application/json")
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting user info: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed getting user info: status %v", res.StatusCode)
+	}
+	// Numbers are kept as written, ids above 2^53 would lose precision as a float64.
+	claims := make(map[string]interface{})
+	decoder := json.NewDecoder(res.Body)
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading user info: %w", err)
+	}
+	return p.identity(claims)
+}
+
+func (p *Provider) identity(claims map[string]interface{}) (*Identity, error) {
+	identity := &Identity{
+		Subject: stringClaim(claims, p.cfg.Claims.Subject),
+		Email:   strings.ToLower(stringClaim(claims, p.cfg.Claims.Email)),
+		Name:    stringClaim(claims, p.cfg.Claims.Name),
+	}
+	if identity.Subject == "" {
+		return nil, ErrMissingSubject
+	}
+	if p.cfg.Claims.EmailVerified != "" {
+		switch verified := claims[p.cfg.Claims.EmailVerified].(type) {
+		case bool:
+			identity.EmailVerified = verified
+		case string:
+			identity.EmailVerified = verified == "true"
+		}
+	}
+	return identity, nil
+}
+
+// stringClaim reads a claim as a string. Numeric claims, like the user ids of some providers, are returned as they were sent.
+func stringClaim(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+	return ""
+}
+
+// endpoints returns the OAuth config and userinfo URL of the provider. Endpoints that aren't configured are read from the discovery document.
+func (p *Provider) endpoints(ctx context.Context) (*oauth2.Config, string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.userInfoURL, nil
+	}
+
+	authURL, tokenURL, userInfoURL := p.cfg.AuthURL, p.cfg.TokenURL, p.cfg.UserInfoURL
+	if authURL == "" || tokenURL == "" || userInfoURL == "" {
+		if p.cfg.Issuer == "" {
+			return nil, "", fmt.Errorf("provider %v has no issuer and is missing endpoints", p.Name)
+		}
+		document, err := p.discover(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		if authURL == "" {
+			authURL = document.AuthorizationEndpoint
+		}
+		if tokenURL == "" {
+			tokenURL = document.TokenEndpoint
+		}
+		if userInfoURL == "" {
+			userInfoURL = document.UserInfoEndpoint
+		}
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+	}
+	p.userInfoURL = userInfoURL
+	return p.oauth, p.userInfoURL, nil
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	url := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discovery of %v failed: %w", p.Name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery of %v failed: status %v", p.Name, res.StatusCode)
+	}
+	document := &discoveryDocument{}
+	err = json.NewDecoder(res.Body).Decode(document)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(document.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("discovery of %v failed: issuer mismatch", p.Name)
+	}
+	return document, nil
+}
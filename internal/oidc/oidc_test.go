@@ -0,0 +1,94 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maskrapp/api/internal/config"
+	"github.com/maskrapp/api/internal/oidc"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIssuer starts a stub issuer that accepts the code "valid" and responds with the given userinfo claims.
+func newIssuer(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "valid" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "access", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	return server
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newIssuer(t, map[string]interface{}{
+		"sub":            "123",
+		"email":          "User@Example.com",
+		"email_verified": true,
+		"name":           "User",
+	})
+	cfg := &config.Config{}
+	cfg.OIDC.Providers = []config.OIDCProvider{{
+		Name:     "stub",
+		Issuer:   issuer.URL,
+		ClientID: "client",
+		Claims:   config.OIDCClaims{Subject: "sub", Email: "email", EmailVerified: "email_verified", Name: "name"},
+	}}
+	registry := oidc.NewRegistry(cfg, issuer.Client())
+
+	provider, ok := registry.Get("stub")
+	assert.True(t, ok)
+
+	identity, err := provider.Exchange(context.Background(), "valid")
+	assert.Nil(t, err)
+	assert.Equal(t, &oidc.Identity{Subject: "123", Email: "user@example.com", EmailVerified: true, Name: "User"}, identity)
+
+	_, err = provider.Exchange(context.Background(), "invalid")
+	assert.NotNil(t, err)
+}
+
+func TestClaimMapping(t *testing.T) {
+	// OAuth 2.0 providers without discovery, like GitHub, use numeric ids and don't report whether the email is verified.
+	issuer := newIssuer(t, map[string]interface{}{
+		"id":    json.Number("9007199254740993"),
+		"email": "user@example.com",
+	})
+	provider := oidc.NewProvider(config.OIDCProvider{
+		Name:        "github",
+		AuthURL:     issuer.URL + "/authorize",
+		TokenURL:    issuer.URL + "/token",
+		UserInfoURL: issuer.URL + "/userinfo",
+		Claims:      config.OIDCClaims{Subject: "id", Email: "email"},
+	}, issuer.Client())
+
+	identity, err := provider.Exchange(context.Background(), "valid")
+	assert.Nil(t, err)
+	assert.Equal(t, "9007199254740993", identity.Subject)
+	assert.False(t, identity.EmailVerified)
+}
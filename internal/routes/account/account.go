@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
 )
 
 // Get responds with the account details of the user
@@ -19,8 +20,34 @@ func Get(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Something went wrong",
 			})
 		}
+		plan, err := ctx.Instances().Entitlements.Plan(userId)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		usage, err := ctx.Instances().Entitlements.Usage(userId)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
 		details := make(map[string]interface{})
 		details["email"] = user.Email
+		details["totp_enabled"] = user.TOTPEnabled
+		details["plan"] = plan.Name
+		details["usage"] = usage
+		// A limit of -1 means that the plan has no limit.
+		details["limits"] = map[string]interface{}{
+			"masks":           plan.MaxMasks,
+			"emails":          plan.MaxEmails,
+			"custom_domains":  plan.MaxCustomDomains,
+			"premium_domains": plan.PremiumDomains,
+		}
 		return c.JSON(details)
 	}
 }
@@ -0,0 +1,151 @@
+package account
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/apikeys"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// maxAPIKeys is the maximum amount of API keys a user can create.
+const maxAPIKeys = 20
+
+// GetAPIKeys is used for retrieving the API keys of the user. Only the prefix of each key is returned.
+// This endpoint is accessible at GET /account/api-keys
+func GetAPIKeys(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		keys := make([]*models.APIKey, 0)
+		err := ctx.Instances().Gorm.Where("user_id = ?", c.Locals("user_id").(string)).Order("created_at ASC").Find(&keys).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(keys)
+	}
+}
+
+// CreateAPIKey is used for creating an API key with the given scopes, which can be used in place of an access token.
+// The key itself is only shown in this response. Re-authentication is required, see reauthenticate.
+// This endpoint is accessible at POST /account/api-keys
+func CreateAPIKey(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Name      string   `json:"name"`
+			Scopes    []string `json:"scopes"`
+			ExpiresAt int64    `json:"expires_at"` // Unix timestamp, 0 means the key never expires.
+			Password  string   `json:"password"`
+			Code      string   `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || len(body.Scopes) == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" || len(body.Name) > 64 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "The name has to be between 1 and 64 characters",
+			})
+		}
+		scopes := make([]string, 0, len(body.Scopes))
+		for _, scope := range body.Scopes {
+			if !apikeys.ValidScope(scope) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Unknown scope: " + scope,
+				})
+			}
+			if !apikeys.HasScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		if body.ExpiresAt != 0 && body.ExpiresAt <= time.Now().Unix() {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "The expiry time has to be in the future",
+			})
+		}
+
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+
+		userID := user.ID
+		db := ctx.Instances().Gorm
+		var count int64
+		err = db.Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if count >= maxAPIKeys {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You have reached the maximum amount of API keys",
+			})
+		}
+
+		key, record, err := apikeys.Generate(db, userID, body.Name, scopes, body.ExpiresAt)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"key":     key,
+			"api_key": record,
+		})
+	}
+}
+
+// DeleteAPIKey is used for revoking an API key of the user.
+// This endpoint is accessible at DELETE /account/api-keys/{id}
+func DeleteAPIKey(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		res := ctx.Instances().Gorm.Delete(&models.APIKey{}, "id = ? AND user_id = ?", c.Params("id"), c.Locals("user_id").(string))
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that API key",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "API key revoked",
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package account
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Delete is used for deleting the account of the user together with all of its data, this can't be undone.
+// Re-authentication is required, see reauthenticate. Tokens of the account stop working right away and its exports are removed.
+// This endpoint is accessible at DELETE /account
+func Delete(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		// Sessions that just signed in don't have to send a body.
+		if len(c.Body()) > 0 {
+			err := c.BodyParser(&body)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				})
+			}
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+
+		err = deleteUser(ctx.Instances().Gorm, user.ID, ctx.Config().Account.ReserveDeletedMasks)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		ctx.Instances().Domains.Refresh()
+		err = deleteExports(ctx, user.ID)
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+		}
+
+		// Refresh tokens stop working because the user doesn't exist anymore, access tokens are refused by the auth middleware until they expire.
+		key := fmt.Sprintf("user-blacklist:%v", user.ID)
+		err = ctx.Instances().Redis.Set(c.Context(), key, 1, ctx.Instances().JWT.AccessTokenExpiry()).Err()
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+		}
+		err = ctx.Instances().Mailer.SendAccountDeletedMail(user.Email)
+		if err != nil {
+			logrus.Errorf("mailer error: %v", err)
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Your account has been deleted",
+		})
+	}
+}
+
+// deleteUser removes the user and everything that belongs to them in one transaction.
+// When reserveMasks is set, the addresses of their masks are kept in reserved_masks.
+func deleteUser(db *gorm.DB, userID string, reserveMasks bool) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		user := &models.User{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, "id = ?", userID).Error
+		if err != nil {
+			return err
+		}
+		if reserveMasks {
+			err = tx.Exec("INSERT INTO reserved_masks (mask, created_at) SELECT mask, NOW() FROM masks WHERE user_id = ? ON CONFLICT DO NOTHING", userID).Error
+			if err != nil {
+				return err
+			}
+		}
+		// Recipients, rules and reverse aliases are removed together with their mask.
+		err = tx.Delete(&models.Mask{}, "user_id = ?", userID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Delete(&models.Domain{}, "user_id = ?", userID).Error
+		if err != nil {
+			return err
+		}
+		emails := tx.Model(&models.Email{}).Select("id").Where("user_id = ?", userID)
+		err = tx.Delete(&models.EmailVerification{}, "email_id IN (?)", emails).Error
+		if err != nil {
+			return err
+		}
+		addresses := tx.Model(&models.Email{}).Select("email").Where("user_id = ?", userID)
+		err = tx.Delete(&models.AccountVerification{}, "email IN (?)", addresses).Error
+		if err != nil {
+			return err
+		}
+		records := []interface{}{
+			&models.Email{},
+			&models.PasswordResetVerification{},
+			&models.MagicLinkVerification{},
+			&models.Provider{},
+			&models.Session{},
+			&models.DomainClaim{},
+			&models.RecoveryCode{},
+			&models.WebAuthnCredential{},
+			&models.SecurityEvent{},
+			&models.APIKey{},
+		}
+		for _, record := range records {
+			err = tx.Delete(record, "user_id = ?", userID).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+}
@@ -0,0 +1,143 @@
+package account
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errEmailNotVerified = errors.New("email isn't verified")
+	errEmailTaken       = errors.New("email is the primary email of another account")
+)
+
+// ChangePrimaryEmail is used for making another verified email of the user their primary email.
+// The primary email is used for signing in with a password and for resetting it, pending reset and sign in codes of the previous email stop working.
+// Re-authentication is required, see reauthenticate. Both the previous and the new email are notified.
+// This endpoint is accessible at PUT /account/email
+func ChangePrimaryEmail(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Email == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if strings.EqualFold(body.Email, user.Email) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That email is already your primary email",
+			})
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+
+		email := &models.Email{}
+		err = ctx.Instances().Gorm.Transaction(func(tx *gorm.DB) error {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(email, "user_id = ? AND email = ?", user.ID, body.Email).Error
+			if err != nil {
+				return err
+			}
+			if !email.IsVerified {
+				return errEmailNotVerified
+			}
+			// Serializes changes to the same address, so that two accounts can't claim it at once.
+			err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext(lower(?)))", email.Email).Error
+			if err != nil {
+				return err
+			}
+			var result struct {
+				Found bool
+			}
+			err = tx.Raw("SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower(?) AND id <> ?) AS found", email.Email, user.ID).Scan(&result).Error
+			if err != nil {
+				return err
+			}
+			if result.Found {
+				return errEmailTaken
+			}
+			err = tx.Model(&models.Email{}).Where("user_id = ?", user.ID).Update("is_primary", gorm.Expr("id = ?", email.Id)).Error
+			if err != nil {
+				return err
+			}
+			res := tx.Model(&models.User{}).Where("id = ? AND email = ?", user.ID, user.Email).Update("email", email.Email)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errAccountChanged
+			}
+			// The codes were sent to the previous email.
+			err = tx.Delete(&models.PasswordResetVerification{}, "user_id = ?", user.ID).Error
+			if err != nil {
+				return err
+			}
+			return tx.Delete(&models.MagicLinkVerification{}, "user_id = ?", user.ID).Error
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That email isn't registered to your account",
+				})
+			case err == errEmailNotVerified:
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That email has to be verified first",
+				})
+			case err == errEmailTaken:
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That email is used by another account",
+				})
+			case err == errAccountChanged:
+				return c.Status(409).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Your account was changed in the meantime, try again",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		for _, address := range []string{user.Email, email.Email} {
+			err = ctx.Instances().Mailer.SendPrimaryEmailChangedMail(address, email.Email)
+			if err != nil {
+				logrus.Errorf("mailer error: %v", err)
+			}
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Your primary email has been changed",
+		})
+	}
+}
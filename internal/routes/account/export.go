@@ -0,0 +1,258 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/export"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// exportExpiry is how long the download link of an export works.
+	exportExpiry = 24 * time.Hour
+	// exportSyncMasks is the amount of masks above which an export is prepared in the background.
+	exportSyncMasks = 500
+
+	exportPending = "pending"
+	exportFailed  = "failed"
+)
+
+var errAccountDeleted = errors.New("account was deleted")
+
+// Export is used for exporting everything that is stored about the user, as JSON or as a zip archive of CSV files.
+// Small accounts get the download link right away. For large accounts the export is prepared in the background and the response is 202,
+// the download link responds with 202 as well until the export is ready. The link is also mailed to the primary email of the user once it's ready.
+// Re-authentication is required, see reauthenticate.
+// This endpoint is accessible at POST /account/export
+func Export(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		body := struct {
+			Format   string `json:"format"`
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}{Format: export.FormatJSON}
+		// Sessions that just signed in don't have to send a body.
+		if len(c.Body()) > 0 {
+			err := c.BodyParser(&body)
+			if err != nil || !export.ValidFormat(body.Format) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				})
+			}
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+		usage, err := ctx.Instances().Entitlements.Usage(user.ID)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			logrus.Errorf("token generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		file := token + "." + body.Format
+		link := ctx.Config().Export.URL + "/" + file
+
+		// Exports are tracked per user, so that they can be removed when the account is deleted.
+		pipe := ctx.Instances().Redis.TxPipeline()
+		pipe.SAdd(c.Context(), userExportsKey(user.ID), file)
+		pipe.Expire(c.Context(), userExportsKey(user.ID), exportExpiry)
+		_, err = pipe.Exec(c.Context())
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		if usage.Masks > exportSyncMasks {
+			err = ctx.Instances().Redis.Set(c.Context(), exportStatusKey(file), exportPending, exportExpiry).Err()
+			if err != nil {
+				logrus.Errorf("redis error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			go func() {
+				err := createExport(ctx, user.ID, file, body.Format)
+				if err == errAccountDeleted {
+					return
+				}
+				if err != nil {
+					logrus.Errorf("export error(%v): %v", user.ID, err)
+					err = ctx.Instances().Redis.Set(ctx, exportStatusKey(file), exportFailed, exportExpiry).Err()
+					if err != nil {
+						logrus.Errorf("redis error: %v", err)
+					}
+					return
+				}
+				err = ctx.Instances().Mailer.SendExportMail(user.Email, link)
+				if err != nil {
+					logrus.Errorf("mailer error: %v", err)
+				}
+			}()
+			return c.Status(202).JSON(fiber.Map{
+				"download_url": link,
+				"expires_at":   time.Now().Add(exportExpiry).Unix(),
+			})
+		}
+
+		err = createExport(ctx, user.ID, file, body.Format)
+		if err != nil {
+			logrus.Errorf("export error(%v): %v", user.ID, err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"download_url": link,
+			"expires_at":   time.Now().Add(exportExpiry).Unix(),
+		})
+	}
+}
+
+// DownloadExport is used for downloading an export through its link, the link works until it expires.
+// Exports that are still being prepared respond with 202, so clients can poll the link.
+// This endpoint is accessible at GET /export/{token}.{format}
+func DownloadExport(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		file := c.Params("file")
+		token, format, found := strings.Cut(file, ".")
+		if !found || token == "" || !export.ValidFormat(format) {
+			return c.SendStatus(404)
+		}
+		data, err := ctx.Instances().Redis.Get(c.Context(), exportKey(file)).Bytes()
+		if err == redis.Nil {
+			return exportStatusResponse(ctx, c, file)
+		}
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		// Sets the content type from the extension as well.
+		c.Attachment("maskr-export." + format)
+		return c.Send(data)
+	}
+}
+
+// exportStatusResponse responds with the status of an export that isn't stored (yet).
+func exportStatusResponse(ctx global.Context, c *fiber.Ctx, file string) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	status, err := ctx.Instances().Redis.Get(c.Context(), exportStatusKey(file)).Result()
+	if err != nil && err != redis.Nil {
+		logrus.Errorf("redis error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	switch status {
+	case exportPending:
+		return c.Status(202).JSON(&models.APIResponse{
+			Success: true,
+			Message: "Your export is being prepared",
+		})
+	case exportFailed:
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Your export could not be prepared, request a new one",
+		})
+	}
+	return c.Status(404).JSON(&models.APIResponse{
+		Success: false,
+		Message: "That export doesn't exist or has expired",
+	})
+}
+
+// createExport builds the export of the user and stores it under the file name until it expires.
+func createExport(ctx global.Context, userID, file, format string) error {
+	result, err := export.Build(ctx.Instances().Gorm, userID)
+	if err != nil {
+		return err
+	}
+	data, err := result.Encode(format)
+	if err != nil {
+		return err
+	}
+	err = ctx.Instances().Redis.Set(ctx, exportKey(file), data, exportExpiry).Err()
+	if err != nil {
+		return err
+	}
+	// The account could have been deleted while the export was built, deleteExports has already run in that case.
+	tracked, err := ctx.Instances().Redis.SIsMember(ctx, userExportsKey(userID), file).Result()
+	if err != nil {
+		return err
+	}
+	if !tracked {
+		err = ctx.Instances().Redis.Del(ctx, exportKey(file)).Err()
+		if err != nil {
+			return err
+		}
+		return errAccountDeleted
+	}
+	return ctx.Instances().Redis.Del(ctx, exportStatusKey(file)).Err()
+}
+
+// deleteExports removes the exports of the user, including the ones that are still being prepared.
+func deleteExports(ctx global.Context, userID string) error {
+	files, err := ctx.Instances().Redis.SMembers(ctx, userExportsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{userExportsKey(userID)}
+	for _, file := range files {
+		keys = append(keys, exportKey(file), exportStatusKey(file))
+	}
+	return ctx.Instances().Redis.Del(ctx, keys...).Err()
+}
+
+func exportKey(file string) string {
+	return fmt.Sprintf("export:%v", file)
+}
+
+// userExportsKey stores the file names of the exports of the user.
+func userExportsKey(userID string) string {
+	return fmt.Sprintf("exports:%v", userID)
+}
+
+// exportStatusKey stores exportPending or exportFailed while an export is prepared in the background.
+func exportStatusKey(file string) string {
+	return fmt.Sprintf("export-status:%v", file)
+}
@@ -0,0 +1,136 @@
+package account
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/maskrapp/api/internal/apikeys"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errAccountChanged is returned when the token version of the user changed while their account was being updated.
+var errAccountChanged = errors.New("account changed")
+
+// ChangePassword is used for changing the password of the user, or for setting one when they signed up through another provider.
+// The current password is required, users without one need a fresh sign in, see reauthenticate.
+// Setting a password enables signing in with the email provider. Every session is signed out,
+// unless `keep_session` is set, in which case a new token pair for the current session is returned. The API keys are revoked.
+// This endpoint is accessible at PUT /account/password
+func ChangePassword(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			CurrentPassword string `json:"current_password"`
+			Password        string `json:"password"`
+			KeepSession     bool   `json:"keep_session"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Password == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		if !utils.IsValidPassword(body.Password) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Password does not meet requirements",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if user.Password != "" {
+			if !utils.CompareHash(body.CurrentPassword, user.Password) {
+				return c.Status(403).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Your current password is incorrect",
+				})
+			}
+			if body.CurrentPassword == body.Password {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You cannot use your previous password",
+				})
+			}
+		} else {
+			valid, err := reauthenticate(ctx, c, user, "", "")
+			if err != nil {
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			if !valid {
+				return c.Status(403).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Sign in again to set a password",
+				})
+			}
+		}
+
+		passwordHash, err := utils.HashPassword(body.Password)
+		if err != nil {
+			logrus.Errorf("hashing error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		db := ctx.Instances().Gorm
+		err = db.Transaction(func(tx *gorm.DB) error {
+			res := tx.Model(&models.User{}).Where("id = ? AND token_version = ?", user.ID, user.TokenVersion).Updates(map[string]interface{}{
+				"password":      passwordHash,
+				"token_version": user.TokenVersion + 1,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errAccountChanged
+			}
+			err := apikeys.RevokeAll(tx, user.ID)
+			if err != nil {
+				return err
+			}
+			var count int64
+			err = tx.Model(&models.Provider{}).Where("user_id = ? AND provider_name = ?", user.ID, "email").Count(&count).Error
+			if err != nil || count > 0 {
+				return err
+			}
+			return tx.Create(&models.Provider{ID: uuid.NewString(), ProviderName: "email", UserID: user.ID}).Error
+		})
+		if err != nil {
+			if err == errAccountChanged {
+				return c.Status(409).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Your account was changed in the meantime, try again",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		if body.KeepSession {
+			return keepCurrentSession(ctx, c, user.ID, user.TokenVersion+1)
+		}
+		// The sessions can't be refreshed anymore, so they are removed from the session list.
+		err = db.Delete(&models.Session{}, "user_id = ?", user.ID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Your password has been changed",
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package account
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+type linkedProvider struct {
+	Provider  string    `json:"provider"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetProviders is used for retrieving the sign in providers that are linked to the account.
+// This endpoint is accessible at GET /account/providers
+func GetProviders(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		providers := make([]*models.Provider, 0)
+		err := ctx.Instances().Gorm.Where("user_id = ?", c.Locals("user_id").(string)).Order("created_at ASC").Find(&providers).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		result := make([]*linkedProvider, 0, len(providers))
+		for _, provider := range providers {
+			result = append(result, &linkedProvider{Provider: provider.ProviderName, CreatedAt: provider.CreatedAt})
+		}
+		return c.JSON(result)
+	}
+}
+
+// LinkProvider is used for linking an additional OpenID Connect or OAuth 2.0 provider to the account.
+// The authorization code is exchanged at the provider, like at POST /auth/signin/{provider}.
+// Re-authentication is required, see reauthenticate. The two-factor code is sent as `two_factor_code`, since `code` is the authorization code.
+// This endpoint is accessible at POST /account/providers/{provider}
+func LinkProvider(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("provider")
+		provider, ok := ctx.Instances().OIDC.Get(name)
+		if !ok {
+			return c.Status(404).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Unknown provider",
+			})
+		}
+		var body struct {
+			Code          string `json:"code"`
+			Password      string `json:"password"`
+			TwoFactorCode string `json:"two_factor_code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.TwoFactorCode)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+
+		userID := user.ID
+		db := ctx.Instances().Gorm
+
+		var count int64
+		err = db.Model(&models.Provider{}).Where("user_id = ? AND provider_name = ?", userID, name).Count(&count).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if count > 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That provider is already linked to your account",
+			})
+		}
+
+		identity, err := provider.Exchange(c.Context(), body.Code)
+		if err != nil {
+			logrus.Errorf("%v token exchange error: %v", name, err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Token exchange error",
+			})
+		}
+
+		err = db.Create(&models.Provider{
+			ID:           models.ProviderID(name, identity.Subject),
+			ProviderName: name,
+			UserID:       userID,
+		}).Error
+		if err != nil {
+			if strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That account is already linked to another user",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Provider linked",
+		})
+	}
+}
+
+// UnlinkProvider is used for unlinking a sign in provider from the account. The last provider can't be unlinked.
+// Re-authentication is required, see reauthenticate.
+// This endpoint is accessible at DELETE /account/providers/{provider}
+func UnlinkProvider(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		// Sessions that just signed in don't have to send a body.
+		if len(c.Body()) > 0 {
+			err := c.BodyParser(&body)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				})
+			}
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+
+		name := c.Params("provider")
+		userID := user.ID
+		db := ctx.Instances().Gorm
+
+		// Locking the user serializes concurrent unlinks, which could otherwise remove every provider together.
+		tx := db.Begin()
+		defer tx.Rollback()
+		err = tx.Exec("SELECT 1 FROM users WHERE id = ? FOR UPDATE", userID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		var count int64
+		err = tx.Model(&models.Provider{}).Where("user_id = ?", userID).Count(&count).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		res := tx.Delete(&models.Provider{}, "user_id = ? AND provider_name = ?", userID, name)
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That provider isn't linked to your account",
+			})
+		}
+		if res.RowsAffected >= count {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You can't unlink your only sign in provider",
+			})
+		}
+		err = tx.Commit().Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Provider unlinked",
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package account
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+)
+
+// reauthWindow is how long a session counts as freshly signed in, see reauthenticate.
+const reauthWindow = 5 * time.Minute
+
+// reauthenticate confirms that a sensitive request is made by the user themself.
+// Sessions that signed in within reauthWindow are trusted. Otherwise the password of the user is required,
+// together with a code of their authenticator app or a recovery code when two-factor authentication is enabled.
+func reauthenticate(ctx global.Context, c *fiber.Ctx, user *models.User, password, code string) (bool, error) {
+	db := ctx.Instances().Gorm
+	sessionID, _ := c.Locals("session_id").(string)
+	if sessionID != "" {
+		session := &models.Session{}
+		err := db.Where("id = ? AND user_id = ?", sessionID, user.ID).Limit(1).Find(session).Error
+		if err != nil {
+			return false, err
+		}
+		if session.ID != "" && time.Since(session.CreatedAt) < reauthWindow {
+			return true, nil
+		}
+	}
+	if user.Password == "" || password == "" || !utils.CompareHash(password, user.Password) {
+		return false, nil
+	}
+	if !user.TOTPEnabled {
+		return true, nil
+	}
+	if code == "" {
+		return false, nil
+	}
+	valid, err := mfa.VerifyTOTP(db, user, code)
+	if err != nil || valid {
+		return valid, err
+	}
+	return mfa.VerifyRecoveryCode(db, user.ID, code)
+}
+
+func reauthFailedResponse(c *fiber.Ctx) error {
+	return c.Status(403).JSON(&models.APIResponse{
+		Success: false,
+		Message: "Confirm with your password and two-factor code, or sign in again",
+	})
+}
@@ -0,0 +1,87 @@
+package account
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// GetRecoveryCodes is used for retrieving which recovery codes of the user have been used. The codes themselves aren't returned.
+// This endpoint is accessible at GET /account/2fa/recovery-codes
+func GetRecoveryCodes(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		codes := make([]*models.RecoveryCode, 0)
+		err := ctx.Instances().Gorm.Where("user_id = ?", c.Locals("user_id").(string)).Order("id ASC").Find(&codes).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		remaining := 0
+		for _, code := range codes {
+			if code.UsedAt == 0 {
+				remaining++
+			}
+		}
+		return c.JSON(fiber.Map{
+			"remaining": remaining,
+			"codes":     codes,
+		})
+	}
+}
+
+// RegenerateRecoveryCodes is used for replacing the recovery codes of the user, a code of their authenticator app is required.
+// The new codes are only shown in this response.
+// This endpoint is accessible at POST /account/2fa/recovery-codes
+func RegenerateRecoveryCodes(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if !user.TOTPEnabled {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Two-factor authentication is not enabled",
+			})
+		}
+		db := ctx.Instances().Gorm
+		valid, err := mfa.VerifyTOTP(db, user, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid code",
+			})
+		}
+		codes, err := mfa.GenerateRecoveryCodes(db, user.ID)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{"recovery_codes": codes})
+	}
+}
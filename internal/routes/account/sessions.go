@@ -0,0 +1,79 @@
+package account
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+type session struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions is used for retrieving the devices that are signed in to the account.
+// This endpoint is accessible at GET /account/sessions
+func GetSessions(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		sessions, err := ctx.Instances().Sessions.List(c.Locals("user_id").(string))
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		current, _ := c.Locals("session_id").(string)
+		result := make([]*session, 0, len(sessions))
+		for _, s := range sessions {
+			result = append(result, &session{Session: s, Current: s.ID == current})
+		}
+		return c.JSON(result)
+	}
+}
+
+// RevokeSession is used for signing out a single device. Its access token stays valid until it expires.
+// This endpoint is accessible at DELETE /account/sessions/{id}
+func RevokeSession(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		found, err := ctx.Instances().Sessions.Revoke(c.Locals("user_id").(string), c.Params("id"))
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !found {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That session doesn't exist",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Session revoked",
+		})
+	}
+}
+
+// RevokeOtherSessions is used for signing out every device except for the one that makes the request.
+// This endpoint is accessible at DELETE /account/sessions
+func RevokeOtherSessions(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		current, _ := c.Locals("session_id").(string)
+		revoked, err := ctx.Instances().Sessions.RevokeOthers(c.Locals("user_id").(string), current)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"revoked": revoked,
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package account
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/apikeys"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/jwt"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/totp"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// EnrollTOTP is used for starting the enrollment of an authenticator app. The enrollment has to be confirmed with a code of the app.
+// Re-authentication is required, see reauthenticate.
+// This endpoint is accessible at POST /account/2fa/totp
+func EnrollTOTP(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Password string `json:"password"`
+		}
+		// Sessions that just signed in don't have to send a body.
+		if len(c.Body()) > 0 {
+			err := c.BodyParser(&body)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				})
+			}
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if user.TOTPEnabled {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Two-factor authentication is already enabled",
+			})
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, "")
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+		return startEnrollment(ctx, c, user)
+	}
+}
+
+// ReenrollTOTP is used for replacing the authenticator app of the user, a code of the current app is required.
+// The new app replaces the current one once the enrollment is confirmed.
+// This endpoint is accessible at POST /account/2fa/totp/re-enroll
+func ReenrollTOTP(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if !user.TOTPEnabled {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Two-factor authentication is not enabled",
+			})
+		}
+		valid, err := mfa.VerifyTOTP(ctx.Instances().Gorm, user, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid code",
+			})
+		}
+		return startEnrollment(ctx, c, user)
+	}
+}
+
+// ConfirmTOTP is used for confirming an enrollment with a code of the new authenticator app.
+// The first enrollment responds with the recovery codes of the user.
+// Replacing an existing app signs out every other session, the new token pair is returned in that case.
+// This endpoint is accessible at POST /account/2fa/totp/confirm
+func ConfirmTOTP(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if user.TOTPPendingSecret == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "There is no enrollment to confirm",
+			})
+		}
+		step, ok := totp.Validate(user.TOTPPendingSecret, body.Code, time.Now())
+		if !ok {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid code",
+			})
+		}
+
+		values := map[string]interface{}{
+			"totp_secret":         user.TOTPPendingSecret,
+			"totp_pending_secret": "",
+			"totp_enabled":        true,
+			"totp_last_step":      step,
+		}
+		if !user.TOTPEnabled {
+			err = ctx.Instances().Gorm.Model(&models.User{}).Where("id = ? AND totp_pending_secret = ?", user.ID, user.TOTPPendingSecret).Updates(values).Error
+			if err != nil {
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			codes, err := mfa.GenerateRecoveryCodes(ctx.Instances().Gorm, user.ID)
+			if err != nil {
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			// The recovery codes can't be retrieved again.
+			return c.JSON(fiber.Map{"recovery_codes": codes})
+		}
+		return updateAndSignOut(ctx, c, user, values)
+	}
+}
+
+// DisableTOTP is used for disabling two-factor authentication, a code of the current authenticator app is required.
+// Every other session is signed out, the API keys are revoked and a new token pair is returned.
+// This endpoint is accessible at DELETE /account/2fa/totp
+func DisableTOTP(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		if !user.TOTPEnabled {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Two-factor authentication is not enabled",
+			})
+		}
+		valid, err := mfa.VerifyTOTP(ctx.Instances().Gorm, user, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid code",
+			})
+		}
+		err = ctx.Instances().Gorm.Delete(&models.RecoveryCode{}, "user_id = ?", user.ID).Error
+		if err == nil {
+			err = apikeys.RevokeAll(ctx.Instances().Gorm, user.ID)
+		}
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return updateAndSignOut(ctx, c, user, map[string]interface{}{
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_enabled":        false,
+			"totp_last_step":      0,
+		})
+	}
+}
+
+// findUser retrieves the user with the given id.
+func findUser(ctx global.Context, userID string) (*models.User, error) {
+	user := &models.User{}
+	err := ctx.Instances().Gorm.First(user, "id = ?", userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func userErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(401).JSON(&models.APIResponse{
+			Success: false,
+			Message: "The user that is associated with your token no longer exists",
+		})
+	}
+	logrus.Errorf("db error: %v", err)
+	return c.Status(500).JSON(&models.APIResponse{
+		Success: false,
+		Message: "Something went wrong",
+	})
+}
+
+func startEnrollment(ctx global.Context, c *fiber.Ctx, user *models.User) error {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logrus.Errorf("secret generation error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	err = ctx.Instances().Gorm.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_pending_secret", secret).Error
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"secret": secret,
+		"uri":    totp.URI(secret, user.Email),
+	})
+}
+
+// updateAndSignOut applies the values to the user and bumps their token version, which invalidates every token.
+// The other sessions are revoked, the current session is kept by responding with a new token pair.
+func updateAndSignOut(ctx global.Context, c *fiber.Ctx, user *models.User, values map[string]interface{}) error {
+	values["token_version"] = user.TokenVersion + 1
+	res := ctx.Instances().Gorm.Model(&models.User{}).Where("id = ? AND token_version = ?", user.ID, user.TokenVersion).Updates(values)
+	if res.Error != nil {
+		logrus.Errorf("db error: %v", res.Error)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(409).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Your account was changed in the meantime, try again",
+		})
+	}
+	return keepCurrentSession(ctx, c, user.ID, user.TokenVersion+1)
+}
+
+// keepCurrentSession revokes every session except for the current one after the token version has been bumped,
+// and responds with a new token pair for the current session. Tokens without a session get a new one.
+func keepCurrentSession(ctx global.Context, c *fiber.Ctx, userID string, tokenVersion int) error {
+	provider, _ := c.Locals("provider").(string)
+	sessionID, _ := c.Locals("session_id").(string)
+	_, err := ctx.Instances().Sessions.RevokeOthers(userID, sessionID)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	var pair *jwt.Pair
+	if sessionID == "" {
+		// Access tokens that were issued before sessions existed get a new session, they have nothing to reissue.
+		pair, err = ctx.Instances().Sessions.Create(c, &models.User{ID: userID, TokenVersion: tokenVersion}, provider)
+	} else {
+		pair, err = ctx.Instances().Sessions.Reissue(userID, tokenVersion, provider, sessionID)
+	}
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	return c.JSON(pair)
+}
@@ -0,0 +1,244 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/webauthn"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxPasskeys is the maximum amount of passkeys a user can register.
+const maxPasskeys = 10
+
+var errTooManyPasskeys = errors.New("too many passkeys")
+
+// PasskeyOptions is used for starting the registration of a passkey. The returned options are passed to navigator.credentials.create.
+// Re-authentication is required, see reauthenticate. AddPasskey only accepts the challenge that is issued here.
+// This endpoint is accessible at POST /account/passkeys/options
+func PasskeyOptions(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		// Sessions that just signed in don't have to send a body.
+		if len(c.Body()) > 0 {
+			err := c.BodyParser(&body)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				})
+			}
+		}
+		user, err := findUser(ctx, c.Locals("user_id").(string))
+		if err != nil {
+			return userErrorResponse(c, err)
+		}
+		valid, err := reauthenticate(ctx, c, user, body.Password, body.Code)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return reauthFailedResponse(c)
+		}
+		var existing []string
+		err = ctx.Instances().Gorm.Model(&models.WebAuthnCredential{}).Where("user_id = ?", user.ID).Pluck("id", &existing).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if len(existing) >= maxPasskeys {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You have reached the maximum amount of passkeys",
+			})
+		}
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			logrus.Errorf("challenge generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		key := fmt.Sprintf("webauthn:registration:%v", user.ID)
+		err = ctx.Instances().Redis.Set(c.Context(), key, challenge, webauthn.Timeout*time.Millisecond+time.Minute).Err()
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"public_key": ctx.Instances().WebAuthn.CreationOptions(challenge, user.ID, user.Email, existing),
+		})
+	}
+}
+
+// AddPasskey is used for completing the registration of a passkey. Binary values of the attestation are base64url encoded.
+// The challenge of PasskeyOptions is required, so the user has re-authenticated for the registration.
+// This endpoint is accessible at POST /account/passkeys
+func AddPasskey(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Name              string `json:"name"`
+			ClientDataJSON    string `json:"client_data_json"`
+			AttestationObject string `json:"attestation_object"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			body.Name = "Passkey"
+		}
+		if len(body.Name) > 64 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That name is too long",
+			})
+		}
+		clientDataJSON, err1 := webauthn.DecodeBase64URL(body.ClientDataJSON)
+		attestationObject, err2 := webauthn.DecodeBase64URL(body.AttestationObject)
+		if err1 != nil || err2 != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		key := fmt.Sprintf("webauthn:registration:%v", userID)
+		challenge, err := ctx.Instances().Redis.GetDel(c.Context(), key).Result()
+		if err != nil {
+			if err == redis.Nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "The registration has expired",
+				})
+			}
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		credential, err := ctx.Instances().WebAuthn.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Passkey verification failed",
+			})
+		}
+
+		record := &models.WebAuthnCredential{
+			ID:        credential.ID,
+			UserID:    userID,
+			Name:      body.Name,
+			PublicKey: credential.PublicKey,
+			Algorithm: credential.Algorithm,
+			SignCount: int64(credential.SignCount),
+		}
+		// The limit is checked again, another session could have added passkeys since the options were issued.
+		err = ctx.Instances().Gorm.Transaction(func(tx *gorm.DB) error {
+			err := tx.Exec("SELECT 1 FROM users WHERE id = ? FOR UPDATE", userID).Error
+			if err != nil {
+				return err
+			}
+			var count int64
+			err = tx.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count >= maxPasskeys {
+				return errTooManyPasskeys
+			}
+			return tx.Create(record).Error
+		})
+		if err != nil {
+			if err == errTooManyPasskeys {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You have reached the maximum amount of passkeys",
+				})
+			}
+			if strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That passkey is already registered",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(record)
+	}
+}
+
+// GetPasskeys is used for retrieving the passkeys of the user.
+// This endpoint is accessible at GET /account/passkeys
+func GetPasskeys(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		credentials := make([]*models.WebAuthnCredential, 0)
+		err := ctx.Instances().Gorm.Where("user_id = ?", c.Locals("user_id").(string)).Order("created_at ASC").Find(&credentials).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(credentials)
+	}
+}
+
+// DeletePasskey is used for removing a passkey of the user.
+// This endpoint is accessible at DELETE /account/passkeys/{id}
+func DeletePasskey(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		res := ctx.Instances().Gorm.Delete(&models.WebAuthnCredential{}, "id = ? AND user_id = ?", c.Params("id"), c.Locals("user_id").(string))
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that passkey",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Passkey removed",
+		})
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/apikeys"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/maskrapp/api/internal/utils"
+	"github.com/maskrapp/api/internal/verification"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
@@ -117,7 +119,11 @@ func Reset(ctx global.Context) func(*fiber.Ctx) error {
 
 		}
 
-		// We update the record if it DOES exist
+		// We update the record if it DOES exist, unless it's locked.
+		err = verification.CheckLock(passwordRecord.LockedUntil)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
 
 		code := utils.GenerateCode(6)
 		hash, err := utils.HashCode(code)
@@ -183,6 +189,7 @@ func VerifyPassword(ctx global.Context) func(*fiber.Ctx) error {
 			VerificationCode string
 			TokenVersion     int
 			ExpiresAt        int64
+			LockedUntil      int64
 		}
 
 		db := ctx.Instances().Gorm
@@ -200,11 +207,12 @@ func VerifyPassword(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
+		err = verification.CheckLock(record.LockedUntil)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
 		if !utils.CompareHash(body.Code, record.VerificationCode) {
-			return c.Status(400).JSON(&models.APIResponse{
-				Message: "Incorrect code",
-				Success: false,
-			})
+			return verification.ErrorResponse(c, verification.Fail(db, verification.PasswordResetVerifications, "id = ?", record.ID))
 		}
 
 		if time.Now().Unix() > record.ExpiresAt {
@@ -230,7 +238,7 @@ func VerifyPassword(ctx global.Context) func(*fiber.Ctx) error {
 	}
 }
 
-// Confirm is the final step in the process.
+// Confirm is the final step in the process. Every session is signed out and the API keys are revoked.
 // This route is accessible at POST /auth/reset-password/confirm.
 func Confirm(ctx global.Context) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
@@ -324,14 +332,25 @@ func Confirm(ctx global.Context) func(*fiber.Ctx) error {
 			Password:     passwordHash,
 		}
 
-		err = db.Model(&models.User{}).Where("id = ?", userRecord.ID).Updates(updatedModel).Error
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&models.User{}).Where("id = ?", userRecord.ID).Updates(updatedModel).Error
+			if err != nil {
+				return err
+			}
+			return apikeys.RevokeAll(tx, userRecord.ID)
+		})
 		if err != nil {
-			logrus.Errorf("db error: %v")
+			logrus.Errorf("db error: %v", err)
 			return c.Status(500).JSON(&models.APIResponse{
 				Success: false,
 				Message: "Something went wrong",
 			})
 		}
+		// The sessions can't be refreshed anymore, so they are removed from the session list.
+		err = db.Delete(&models.Session{}, "user_id = ?", userRecord.ID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+		}
 
 		return c.Status(200).JSON(&models.APIResponse{
 			Success: true,
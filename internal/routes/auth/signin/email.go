@@ -3,6 +3,7 @@ package signin
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/maskrapp/api/internal/utils"
 	"github.com/sirupsen/logrus"
@@ -63,7 +64,19 @@ func Email(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
-		pair, err := ctx.Instances().JWT.CreatePair(user.ID, user.TokenVersion, "email")
+		// Users with two-factor authentication get a short-lived token that has to be exchanged at POST /auth/signin/mfa.
+		if mfa.Enabled(user) {
+			token, err := ctx.Instances().JWT.GenerateMFAToken(user.ID, user.TokenVersion, "email")
+			if err != nil {
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": token})
+		}
+
+		pair, err := ctx.Instances().Sessions.Create(c, user, "email")
 		if err != nil {
 			return c.Status(500).JSON(&models.APIResponse{
 				Success: false,
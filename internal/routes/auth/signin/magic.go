@@ -0,0 +1,236 @@
+package signin
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// magicLinkExpiry is how long a magic link and its code can be redeemed.
+const magicLinkExpiry = 15 * time.Minute
+
+// Magic is used for requesting a passwordless sign in. A link and a code are sent to the primary email of the user,
+// as long as that email is verified. Users of every provider can sign in this way.
+// The response is the same whether or not the email belongs to an account.
+// This endpoint is accessible at: POST /auth/signin/magic
+func Magic(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Email        string `json:"email"`
+			CaptchaToken string `json:"captcha_token"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Email == "" || body.CaptchaToken == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		if !utils.EmailRegex.MatchString(body.Email) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid email",
+			})
+		}
+		if !ctx.Instances().Recaptcha.ValidateCaptchaToken(body.CaptchaToken, "magic_login") {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Captcha failed. Try again."})
+		}
+
+		db := ctx.Instances().Gorm
+		user := &models.User{}
+		err = db.Table("users").Select("users.*").Joins("INNER JOIN emails ON emails.user_id = users.id AND emails.email = users.email").Where("users.email = ? AND emails.is_primary AND emails.is_verified", body.Email).Limit(1).Find(user).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if user.ID == "" {
+			return c.JSON(&models.APIResponse{
+				Success: true,
+			})
+		}
+
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			logrus.Errorf("token generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		code, err := utils.GenerateSecureCode(6)
+		if err != nil {
+			logrus.Errorf("code generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		tokenHash, err := utils.HashCode(token)
+		if err != nil {
+			logrus.Errorf("hashing error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		codeHash, err := utils.HashCode(code)
+		if err != nil {
+			logrus.Errorf("hashing error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		// Requesting a new link invalidates the previous one.
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Delete(&models.MagicLinkVerification{}, "user_id = ?", user.ID).Error
+			if err != nil {
+				return err
+			}
+			return tx.Create(&models.MagicLinkVerification{
+				UserID:    user.ID,
+				TokenHash: tokenHash,
+				CodeHash:  codeHash,
+				ExpiresAt: time.Now().Add(magicLinkExpiry).Unix(),
+			}).Error
+		})
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		query := url.Values{}
+		query.Set("email", user.Email)
+		query.Set("token", token)
+		link := ctx.Config().MagicLink.URL + "?" + query.Encode()
+		err = ctx.Instances().Mailer.SendMagicLinkMail(user.Email, code, link)
+		if err != nil {
+			logrus.Errorf("mailer error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+		})
+	}
+}
+
+// RedeemMagic is used for exchanging the token of a magic link, or the code from the same mail, for a token pair.
+// Users with two-factor authentication get an mfa token instead, like at POST /auth/signin/email.
+// This endpoint is accessible at: POST /auth/signin/magic/redeem
+func RedeemMagic(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Email string `json:"email"`
+			Token string `json:"token"`
+			Code  string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Email == "" || (body.Token == "") == (body.Code == "") {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		db := ctx.Instances().Gorm
+		record := &models.MagicLinkVerification{}
+		err = db.Table("magic_link_verifications").Select("magic_link_verifications.*").Joins("INNER JOIN users ON users.id = magic_link_verifications.user_id").Where("users.email = ?", body.Email).First(record).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid or expired link",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if record.ExpiresAt < time.Now().Unix() {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired link",
+			})
+		}
+		var valid bool
+		if body.Token != "" {
+			valid = utils.CompareHash(body.Token, record.TokenHash)
+		} else {
+			valid = utils.CompareHash(strings.TrimSpace(body.Code), record.CodeHash)
+		}
+		if !valid {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired link",
+			})
+		}
+
+		// Only the request that deletes the record signs in, so the link can't be redeemed twice.
+		res := db.Delete(&models.MagicLinkVerification{}, "id = ?", record.ID)
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired link",
+			})
+		}
+
+		user := &models.User{}
+		err = db.First(user, "id = ?", record.UserID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if mfa.Enabled(user) {
+			token, err := ctx.Instances().JWT.GenerateMFAToken(user.ID, user.TokenVersion, "magic")
+			if err != nil {
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": token})
+		}
+
+		pair, err := ctx.Instances().Sessions.Create(c, user, "magic")
+		if err != nil {
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(pair)
+	}
+}
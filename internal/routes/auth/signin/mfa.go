@@ -0,0 +1,102 @@
+package signin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MFA is used for completing the sign in of users with two-factor authentication.
+// The token returned by the first sign in step is exchanged for a token pair, along with a code of the user's authenticator
+// or one of their recovery codes.
+// This endpoint is accessible at: POST /auth/signin/mfa
+func MFA(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Token        string `json:"mfa_token"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Token == "" || (body.Code == "") == (body.RecoveryCode == "") {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		claims, err := ctx.Instances().JWT.ValidateMFAToken(body.Token)
+		if err != nil {
+			return c.Status(401).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid token",
+			})
+		}
+
+		// Limits the amount of guesses per user, regardless of the amount of tokens that were requested.
+		if ctx.Instances().RateLimiter.CheckRateLimit(ctx, claims.UserId, c.Path(), 5, 5*time.Minute) {
+			return c.Status(429).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You are being rate limited",
+			})
+		}
+
+		user := &models.User{}
+		db := ctx.Instances().Gorm
+		err = db.First(user, "id = ?", claims.UserId).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(401).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid token",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if claims.Version != user.TokenVersion {
+			return c.Status(401).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Token version mismatch",
+			})
+		}
+
+		var valid bool
+		if body.RecoveryCode != "" {
+			valid, err = mfa.VerifyRecoveryCode(db, user.ID, body.RecoveryCode)
+		} else {
+			valid, err = mfa.VerifyTOTP(db, user, body.Code)
+		}
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !valid {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid code",
+			})
+		}
+
+		pair, err := ctx.Instances().Sessions.Create(c, user, claims.Provider)
+		if err != nil {
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(pair)
+	}
+}
@@ -0,0 +1,184 @@
+package signin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/mfa"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/oidc"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Provider is used for authenticating users with one of the configured OpenID Connect or OAuth 2.0 providers, like `google`.
+// The authorization code is exchanged at the provider. On the first sign in, the provider is linked to the user with the same verified email,
+// otherwise a new user is created.
+// This endpoint is accessible at: POST /auth/signin/{provider}
+func Provider(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("provider")
+		provider, ok := ctx.Instances().OIDC.Get(name)
+		if !ok {
+			return c.Status(404).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Unknown provider",
+			})
+		}
+
+		var body struct {
+			Code string `json:"code"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.Code == "" {
+			return c.Status(fiber.ErrBadRequest.Code).JSON(
+				&models.APIResponse{
+					Success: false,
+					Message: "Invalid body",
+				},
+			)
+		}
+		identity, err := provider.Exchange(c.Context(), body.Code)
+		if err != nil {
+			logrus.Errorf("%v token exchange error: %v", name, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(
+				&models.APIResponse{
+					Success: false,
+					Message: "Token exchange error",
+				},
+			)
+		}
+
+		db := ctx.Instances().Gorm
+		providerRecord := &models.Provider{}
+		err = db.First(providerRecord, "id = ?", models.ProviderID(name, identity.Subject)).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.Error("Database error:", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+
+		user := &models.User{}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if identity.Email == "" {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Your account at that provider doesn't have an email address",
+				})
+			}
+			existing, err := findVerifiedUser(db, identity.Email)
+			if err != nil {
+				logrus.Error("Database error:", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong!",
+				})
+			}
+			if existing != nil {
+				// Accounts are only matched when both sides verified the email. Accounts with two-factor authentication
+				// are never matched, since signing in with a provider would skip their second factor.
+				if !identity.EmailVerified || mfa.Enabled(existing) {
+					return c.Status(409).JSON(&models.APIResponse{
+						Success: false,
+						Message: "An account with that email already exists. Sign in and link the provider from your account settings.",
+					})
+				}
+				err = db.Create(&models.Provider{
+					ID:           models.ProviderID(name, identity.Subject),
+					ProviderName: name,
+					UserID:       existing.ID,
+				}).Error
+				user = existing
+			} else {
+				user, err = createProviderUser(db, name, identity)
+			}
+			if err != nil {
+				logrus.Error("Database error:", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong!",
+				})
+			}
+		} else {
+			err = db.First(user, "id = ?", providerRecord.UserID).Error
+			if err != nil {
+				logrus.Error("Database error:", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong!",
+				})
+			}
+		}
+
+		// Users with two-factor authentication get a short-lived token that has to be exchanged at POST /auth/signin/mfa.
+		if mfa.Enabled(user) {
+			token, err := ctx.Instances().JWT.GenerateMFAToken(user.ID, user.TokenVersion, name)
+			if err != nil {
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong!",
+				})
+			}
+			return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": token})
+		}
+
+		pair, err := ctx.Instances().Sessions.Create(c, user, name)
+		if err != nil {
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		return c.JSON(pair)
+	}
+}
+
+// findVerifiedUser retrieves the user whose primary email is the given email, if they verified it. Nil is returned when there is no such user.
+func findVerifiedUser(db *gorm.DB, email string) (*models.User, error) {
+	user := &models.User{}
+	err := db.Table("users").Select("users.*").Joins("INNER JOIN emails ON emails.user_id = users.id AND emails.email = users.email").Where("users.email = ? AND emails.is_primary AND emails.is_verified", email).Limit(1).Find(user).Error
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == "" {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// createProviderUser creates a user along with their provider and primary email.
+func createProviderUser(db *gorm.DB, name string, identity *oidc.Identity) (*models.User, error) {
+	user := &models.User{
+		ID:    uuid.NewString(),
+		Role:  0,
+		Email: identity.Email,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(user).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&models.Provider{
+			ID:           models.ProviderID(name, identity.Subject),
+			ProviderName: name,
+			UserID:       user.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.Email{
+			UserID:     user.ID,
+			IsPrimary:  true,
+			IsVerified: identity.EmailVerified,
+			Email:      identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
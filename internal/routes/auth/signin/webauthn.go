@@ -0,0 +1,173 @@
+package signin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/webauthn"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// WebAuthnOptions is used for starting a passkey sign in. The returned options are passed to navigator.credentials.get,
+// the session id has to be sent along with the result.
+// This endpoint is accessible at: POST /auth/signin/webauthn/options
+func WebAuthnOptions(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			logrus.Errorf("challenge generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		sessionID := uuid.NewString()
+		key := fmt.Sprintf("webauthn:signin:%v", sessionID)
+		err = ctx.Instances().Redis.Set(c.Context(), key, challenge, webauthn.Timeout*time.Millisecond+time.Minute).Err()
+		if err != nil {
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"session_id": sessionID,
+			"public_key": ctx.Instances().WebAuthn.RequestOptions(challenge),
+		})
+	}
+}
+
+// WebAuthn is used for authenticating users with a passkey, using the `webauthn` provider.
+// Binary values of the assertion are base64url encoded.
+// This endpoint is accessible at: POST /auth/signin/webauthn
+func WebAuthn(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			SessionID         string `json:"session_id"`
+			CredentialID      string `json:"credential_id"`
+			ClientDataJSON    string `json:"client_data_json"`
+			AuthenticatorData string `json:"authenticator_data"`
+			Signature         string `json:"signature"`
+			UserHandle        string `json:"user_handle"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil || body.SessionID == "" || body.CredentialID == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		clientDataJSON, err1 := webauthn.DecodeBase64URL(body.ClientDataJSON)
+		authenticatorData, err2 := webauthn.DecodeBase64URL(body.AuthenticatorData)
+		signature, err3 := webauthn.DecodeBase64URL(body.Signature)
+		userHandle, err4 := webauthn.DecodeBase64URL(body.UserHandle)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		// The challenge is removed right away, so that every challenge can only be answered once.
+		key := fmt.Sprintf("webauthn:signin:%v", body.SessionID)
+		challenge, err := ctx.Instances().Redis.GetDel(c.Context(), key).Result()
+		if err != nil {
+			if err == redis.Nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "The sign in session has expired",
+				})
+			}
+			logrus.Errorf("redis error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		db := ctx.Instances().Gorm
+		record := &models.WebAuthnCredential{}
+		err = db.First(record, "id = ?", strings.TrimRight(body.CredentialID, "=")).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Unknown passkey",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if len(userHandle) > 0 && string(userHandle) != record.UserID {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Unknown passkey",
+			})
+		}
+
+		credential := &webauthn.Credential{
+			ID:        record.ID,
+			PublicKey: record.PublicKey,
+			Algorithm: record.Algorithm,
+			SignCount: uint32(record.SignCount),
+		}
+		signCount, err := ctx.Instances().WebAuthn.VerifyAssertion(challenge, credential, clientDataJSON, authenticatorData, signature)
+		if err != nil {
+			if err == webauthn.ErrCloned {
+				logrus.Warnf("passkey %v of user %v: %v", record.ID, record.UserID, err)
+			}
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Passkey verification failed",
+			})
+		}
+
+		res := db.Model(&models.WebAuthnCredential{}).Where("id = ? AND sign_count = ?", record.ID, record.SignCount).Updates(map[string]interface{}{
+			"sign_count":   signCount,
+			"last_used_at": time.Now().Unix(),
+		})
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Passkey verification failed",
+			})
+		}
+
+		user := &models.User{}
+		err = db.First(user, "id = ?", record.UserID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		pair, err := ctx.Instances().Sessions.Create(c, user, "webauthn")
+		if err != nil {
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(pair)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/maskrapp/api/internal/utils"
+	"github.com/maskrapp/api/internal/verification"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
@@ -105,6 +106,11 @@ func Signup(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
+		err = verification.CheckLock(verificationRecord.LockedUntil)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
+
 		// We don't need to update the record if it hasn't expired yet.
 		if time.Now().Unix() < verificationRecord.ExpiresAt {
 			return c.Status(200).JSON(&models.APIResponse{
@@ -186,6 +192,11 @@ func ResendCode(ctx global.Context) func(*fiber.Ctx) error {
 
 		}
 
+		err = verification.CheckLock(verificationRecord.LockedUntil)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
+
 		verificationCode := utils.GenerateCode(5)
 		err = db.Model(&models.AccountVerification{}).Where("email = ?", verificationRecord.Email).Updates(&models.AccountVerification{VerificationCode: verificationCode, ExpiresAt: time.Now().Add(5 * time.Minute).Unix()}).Error
 		if err != nil {
@@ -245,7 +256,7 @@ func VerifySignup(ctx global.Context) func(*fiber.Ctx) error {
 		db := ctx.Instances().Gorm
 
 		verificationRecord := &models.AccountVerification{}
-		err = db.First(verificationRecord, "email = ? AND verification_code = ? ", body.Email, body.Code).Error
+		err = db.First(verificationRecord, "email = ?", body.Email).Error
 
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -260,6 +271,10 @@ func VerifySignup(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Something went wrong",
 			})
 		}
+		err = checkAccountCode(db, verificationRecord, body.Code)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
 		return c.JSON(&models.APIResponse{
 			Success: true,
 			Message: "Code is valid",
@@ -310,7 +325,7 @@ func Create(ctx global.Context) func(*fiber.Ctx) error {
 
 		verificationRecord := &models.AccountVerification{}
 		db := ctx.Instances().Gorm
-		err = db.First(verificationRecord, "email = ? AND verification_code = ? ", body.Email, body.Code).Error
+		err = db.First(verificationRecord, "email = ?", body.Email).Error
 
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -325,6 +340,10 @@ func Create(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Something went wrong",
 			})
 		}
+		err = checkAccountCode(db, verificationRecord, body.Code)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
 
 		err = db.Delete(&models.AccountVerification{}, "email = ?", body.Email).Error
 
@@ -379,7 +398,7 @@ func Create(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
-		pair, err := ctx.Instances().JWT.CreatePair(user.ID, user.TokenVersion, "email")
+		pair, err := ctx.Instances().Sessions.Create(c, user, "email")
 
 		if err != nil {
 			return c.Status(500).JSON(&models.APIResponse{
@@ -390,3 +409,15 @@ func Create(ctx global.Context) func(*fiber.Ctx) error {
 		return c.JSON(pair)
 	}
 }
+
+// checkAccountCode compares the code with the code of a signup verification, incorrect codes count towards locking it.
+func checkAccountCode(db *gorm.DB, record *models.AccountVerification, code string) error {
+	err := verification.CheckLock(record.LockedUntil)
+	if err != nil {
+		return err
+	}
+	if record.VerificationCode == "" || code != record.VerificationCode {
+		return verification.Fail(db, verification.AccountVerifications, "email = ?", record.Email)
+	}
+	return nil
+}
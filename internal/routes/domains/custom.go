@@ -0,0 +1,443 @@
+package domains
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/dns"
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type customDomain struct {
+	Domain        string       `json:"domain"`
+	Verified      bool         `json:"verified"`
+	MX            bool         `json:"mx"`
+	SPF           bool         `json:"spf"`
+	DKIM          bool         `json:"dkim"`
+	CheckedAt     int64        `json:"checked_at"`
+	CatchAll      bool         `json:"catch_all"`
+	CatchAllEmail string       `json:"catch_all_email,omitempty"`
+	Records       []dns.Record `json:"records"`
+}
+
+func toCustomDomain(ctx global.Context, domain *models.Domain) *customDomain {
+	var catchAllEmail string
+	if domain.CatchAllEmail != nil {
+		catchAllEmail = domain.CatchAllEmail.Email
+	}
+	return &customDomain{
+		Domain:        domain.Domain,
+		Verified:      domain.Verified,
+		MX:            domain.MXValid,
+		SPF:           domain.SPFValid,
+		DKIM:          domain.DKIMValid,
+		CheckedAt:     domain.CheckedAt,
+		CatchAll:      domain.CatchAll,
+		CatchAllEmail: catchAllEmail,
+		Records:       ctx.Instances().DNS.Records(domain.Domain, domain.VerificationToken),
+	}
+}
+
+// GetCustom is used for retrieving the user's custom domains, along with the DNS records they have to create.
+// This endpoint is accessible at GET /domains/custom
+func GetCustom(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("user_id").(string)
+		domains := make([]*models.Domain, 0)
+		err := ctx.Instances().Gorm.Preload("CatchAllEmail").Where("user_id = ?", userID).Order("created_at DESC").Find(&domains).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		result := make([]*customDomain, 0, len(domains))
+		for _, domain := range domains {
+			result = append(result, toCustomDomain(ctx, domain))
+		}
+		claims := make([]*models.DomainClaim, 0)
+		err = ctx.Instances().Gorm.Where("user_id = ?", userID).Order("created_at DESC").Find(&claims).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		for _, claim := range claims {
+			result = append(result, toCustomDomain(ctx, &models.Domain{Domain: claim.Domain, VerificationToken: claim.VerificationToken}))
+		}
+		return c.JSON(result)
+	}
+}
+
+// AddCustom is used for registering a custom domain. The domain can be used once its ownership has been verified.
+// This endpoint is accessible at POST /domains/custom
+func AddCustom(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Domain string `json:"domain"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		domainName := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(body.Domain)), ".")
+		if !utils.HostnameRegex.MatchString(domainName) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid domain",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		err = ctx.Instances().Entitlements.CanAddCustomDomain(userID)
+		if err != nil {
+			if err == entitlements.ErrCustomDomainLimit {
+				return c.Status(403).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You have reached the maximum amount of custom domains of your plan",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		token, err := utils.GenerateRandomString(32)
+		if err != nil {
+			logrus.Errorf("token generation error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		var result struct {
+			Found bool
+		}
+		err = db.Raw("SELECT EXISTS(SELECT 1 FROM domains WHERE domain = ?) AS found", domainName).Scan(&result).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if result.Found {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That domain is already registered",
+			})
+		}
+
+		// The domain is only claimed by this user until the ownership record is verified, so it can't be squatted.
+		claim := &models.DomainClaim{
+			Domain:            domainName,
+			UserID:            userID,
+			VerificationToken: token,
+		}
+		err = db.Create(claim).Error
+		if err != nil {
+			if strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You already added that domain",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		domain := &models.Domain{Domain: claim.Domain, VerificationToken: claim.VerificationToken}
+		return c.JSON(toCustomDomain(ctx, domain))
+	}
+}
+
+// VerifyCustom is used for checking the DNS records of a custom domain.
+// Claimed domains are registered to the user when the ownership record is found. The MX, SPF and DKIM records are checked every time.
+// This endpoint is accessible at POST /domains/custom/{domain}/verify
+func VerifyCustom(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("user_id").(string)
+		domainName := strings.ToLower(c.Params("domain"))
+		db := ctx.Instances().Gorm
+
+		domain := &models.Domain{}
+		err := db.Preload("CatchAllEmail").First(domain, "domain = ? AND user_id = ?", domainName, userID).Error
+		claimed := errors.Is(err, gorm.ErrRecordNotFound)
+		if claimed {
+			claim := &models.DomainClaim{}
+			err = db.First(claim, "domain = ? AND user_id = ?", domainName, userID).Error
+			domain = &models.Domain{
+				Domain:            claim.Domain,
+				Free:              true,
+				UserID:            &userID,
+				VerificationToken: claim.VerificationToken,
+			}
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You don't own that domain",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		if claimed {
+			verified, err := ctx.Instances().DNS.VerifyOwnership(lookupCtx, domain.Domain, domain.VerificationToken)
+			if err != nil {
+				logrus.Errorf("dns error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			if !verified {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "The verification record could not be found",
+				})
+			}
+		}
+
+		result, err := ctx.Instances().DNS.Check(lookupCtx, domain.Domain)
+		if err != nil {
+			logrus.Errorf("dns error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		domain.Verified = true
+		domain.MXValid = result.MX
+		domain.SPFValid = result.SPF
+		domain.DKIMValid = result.DKIM
+		domain.CheckedAt = time.Now().Unix()
+		if claimed {
+			// The other claims on the domain can never be verified anymore, since it's registered now.
+			err = db.Transaction(func(tx *gorm.DB) error {
+				err := tx.Create(domain).Error
+				if err != nil {
+					return err
+				}
+				return tx.Delete(&models.DomainClaim{}, "domain = ?", domain.Domain).Error
+			})
+			if err != nil && strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That domain is already registered",
+				})
+			}
+		} else {
+			values := map[string]interface{}{
+				"verified":   domain.Verified,
+				"mx_valid":   domain.MXValid,
+				"spf_valid":  domain.SPFValid,
+				"dkim_valid": domain.DKIMValid,
+				"checked_at": domain.CheckedAt,
+			}
+			err = db.Model(&models.Domain{}).Where("domain = ?", domain.Domain).Updates(values).Error
+		}
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		ctx.Instances().Domains.Refresh()
+		return c.JSON(toCustomDomain(ctx, domain))
+	}
+}
+
+// CatchAll is used for configuring the catch-all setting of a verified custom domain.
+// When it is enabled, mail to unknown addresses on the domain creates a new mask that forwards to the given email.
+// This endpoint is accessible at PUT /domains/custom/{domain}/catch-all
+func CatchAll(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Enabled bool   `json:"enabled"`
+			Email   string `json:"email"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		if body.Enabled && body.Email == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		domain := &models.Domain{}
+		err = db.First(domain, "domain = ? AND user_id = ?", strings.ToLower(c.Params("domain")), userID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You don't own that domain",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !domain.Verified {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That domain is not verified",
+			})
+		}
+
+		values := map[string]interface{}{
+			"catch_all":          false,
+			"catch_all_email_id": nil,
+		}
+		if body.Enabled {
+			emailRecord := &models.Email{}
+			err = db.First(emailRecord, "email = ? AND user_id = ?", body.Email, userID).Error
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(400).JSON(&models.APIResponse{
+						Success: false,
+						Message: "You don't own that email",
+					})
+				}
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			if !emailRecord.IsVerified {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Email is not verified",
+				})
+			}
+			values["catch_all"] = true
+			values["catch_all_email_id"] = emailRecord.Id
+		}
+
+		err = db.Model(&models.Domain{}).Where("domain = ?", domain.Domain).Updates(values).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		ctx.Instances().Domains.Refresh()
+		return c.JSON(&models.APIResponse{
+			Success: true,
+		})
+	}
+}
+
+// DeleteCustom is used for deleting a custom domain.
+// This endpoint is accessible at DELETE /domains/custom/{domain}
+func DeleteCustom(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("user_id").(string)
+		domainName := strings.ToLower(c.Params("domain"))
+		if !utils.HostnameRegex.MatchString(domainName) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid domain",
+			})
+		}
+		db := ctx.Instances().Gorm
+
+		res := db.Delete(&models.DomainClaim{}, "domain = ? AND user_id = ?", domainName, userID)
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		if res.RowsAffected > 0 {
+			return c.JSON(&models.APIResponse{
+				Success: true,
+				Message: "Domain deleted",
+			})
+		}
+
+		var result struct {
+			Found bool
+		}
+		err := db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask LIKE ?) AS found",
+			"%@"+domainName).Scan(&result).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		if result.Found {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "There are still masks on that domain.",
+			})
+		}
+
+		res = db.Delete(&models.Domain{}, "domain = ? AND user_id = ?", domainName, userID)
+		if res.Error != nil {
+			logrus.Errorf("db error: %v", res.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		if res.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that domain",
+			})
+		}
+		ctx.Instances().Domains.Refresh()
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Domain deleted",
+		})
+	}
+}
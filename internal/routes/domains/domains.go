@@ -5,11 +5,12 @@ import (
 	"github.com/maskrapp/api/internal/global"
 )
 
-// Get is used for retrieving the available domains
+// Get is used for retrieving the available domains, including the user's verified custom domains
 // This endpoint is accessible at GET /domains
 func Get(ctx global.Context) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
-		availableDomains := ctx.Instances().Domains.Values()
+		userID := c.Locals("user_id").(string)
+		availableDomains := ctx.Instances().Domains.ValuesForUser(userID)
 		return c.JSON(availableDomains)
 	}
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/maskrapp/api/internal/utils"
+	"github.com/maskrapp/api/internal/verification"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
@@ -75,11 +76,12 @@ func Verify(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
+		err = verification.CheckLock(verificationModel.LockedUntil)
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
 		if body.Code != verificationModel.VerificationCode {
-			return c.Status(400).JSON(&models.APIResponse{
-				Success: false,
-				Message: "Invalid code",
-			})
+			return verification.ErrorResponse(c, verification.Fail(db, verification.EmailVerifications, "id = ?", verificationModel.Id))
 		}
 
 		if time.Now().Unix() > verificationModel.ExpiresAt {
@@ -153,13 +155,23 @@ func RequestCode(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
-		verification := &models.EmailVerification{
+		// A locked verification can't get a new code until the lock has passed.
+		existing := &models.EmailVerification{}
+		err = db.Where("email_id = ?", emailRecord.Id).Limit(1).Find(existing).Error
+		if err == nil {
+			err = verification.CheckLock(existing.LockedUntil)
+		}
+		if err != nil {
+			return verification.ErrorResponse(c, err)
+		}
+
+		record := &models.EmailVerification{
 			EmailID:          emailRecord.Id,
 			VerificationCode: utils.GenerateCode(5),
 			ExpiresAt:        time.Now().Add(5 * time.Minute).Unix(),
 		}
-		if db.Model(&verification).Where("email_id = ?", emailRecord.Id).Updates(&verification).RowsAffected == 0 {
-			err = db.Create(&verification).Error
+		if db.Model(&record).Where("email_id = ?", emailRecord.Id).Updates(&record).RowsAffected == 0 {
+			err = db.Create(&record).Error
 		}
 		if err != nil {
 			logrus.Errorf("db error: %v", err)
@@ -168,7 +180,7 @@ func RequestCode(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Something went wrong!",
 			})
 		}
-		err = ctx.Instances().Mailer.SendVerifyMail(email, verification.VerificationCode)
+		err = ctx.Instances().Mailer.SendVerifyMail(email, record.VerificationCode)
 		if err != nil {
 			logrus.Errorf("mailer error: %v", err)
 			return c.Status(500).JSON(&models.APIResponse{
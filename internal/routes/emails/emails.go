@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/entitlements"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
 	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
 )
 
 // Get is used for retrieving the user's emails.
@@ -80,6 +82,21 @@ func Add(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 
+		err = ctx.Instances().Entitlements.CanAddEmail(userId)
+		if err != nil {
+			if err == entitlements.ErrEmailLimit {
+				return c.Status(403).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You have reached the maximum amount of emails of your plan",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
 		emailRecord := &models.Email{
 			UserID:     userId,
 			Email:      body.Email,
@@ -0,0 +1,64 @@
+package masks
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// cursor points at the last mask of a page. It holds the value of the sorted column and the mask itself, which breaks ties.
+type cursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Count     int       `json:"n,omitempty"`
+	Mask      string    `json:"m"`
+}
+
+func newCursor(sort string, m mask) *cursor {
+	c := &cursor{Sort: sort, Mask: m.Mask}
+	switch sort {
+	case "messages_received":
+		c.Count = m.MessagesReceived
+	case "messages_forwarded":
+		c.Count = m.MessagesForwarded
+	default:
+		c.CreatedAt = m.CreatedAt
+	}
+	return c
+}
+
+func (c *cursor) value() interface{} {
+	if c.Sort == "created_at" {
+		return c.CreatedAt
+	}
+	return c.Count
+}
+
+func (c *cursor) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	c := &cursor{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var likeReplacer = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(value string) string {
+	return likeReplacer.Replace(value)
+}
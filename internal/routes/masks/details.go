@@ -0,0 +1,145 @@
+package masks
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxLabelLength = 64
+	maxNoteLength  = 500
+)
+
+// Update is used for editing the label, note, website and limits of an existing mask.
+// Fields that are left out of the body are not changed, an empty string clears the field.
+// This route is accessible at: PATCH /masks/{mask}
+func Update(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Label          *string `json:"label"`
+			Note           *string `json:"note"`
+			UsedOn         *string `json:"used_on"`
+			ExpiresAt      *int64  `json:"expires_at"`
+			MaxReceived    *int    `json:"max_received"`
+			DeleteOnExpiry *bool   `json:"delete_on_expiry"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		mask := c.Params("mask")
+		if mask == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Missing mask parameter",
+			})
+		}
+
+		values := make(map[string]interface{})
+		if body.Label != nil {
+			if len(*body.Label) > maxLabelLength {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Label is too long",
+				})
+			}
+			values["label"] = *body.Label
+		}
+		if body.Note != nil {
+			if len(*body.Note) > maxNoteLength {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Note is too long",
+				})
+			}
+			values["note"] = *body.Note
+		}
+		if body.UsedOn != nil {
+			hostname := ""
+			if *body.UsedOn != "" {
+				hostname, err = utils.NormalizeHostname(*body.UsedOn)
+				if err != nil {
+					return c.Status(400).JSON(&models.APIResponse{
+						Success: false,
+						Message: "Invalid hostname",
+					})
+				}
+			}
+			values["used_on"] = hostname
+		}
+		if body.ExpiresAt != nil {
+			if response := validateLimits(*body.ExpiresAt, 0); response != nil {
+				return c.Status(400).JSON(response)
+			}
+			values["expires_at"] = *body.ExpiresAt
+		}
+		if body.MaxReceived != nil {
+			if response := validateLimits(0, *body.MaxReceived); response != nil {
+				return c.Status(400).JSON(response)
+			}
+			values["max_received"] = *body.MaxReceived
+		}
+		if body.DeleteOnExpiry != nil {
+			values["delete_on_expiry"] = *body.DeleteOnExpiry
+		}
+		if len(values) == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		result := ctx.Instances().Gorm.Model(&models.Mask{}).Where("mask = ? AND user_id = ?", mask, userID).Updates(values)
+		if result.Error != nil {
+			logrus.Errorf("db error: %v", result.Error)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if result.RowsAffected == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that mask",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+		})
+	}
+}
+
+// Lookup is used for retrieving the user's masks that are used on the given website.
+// This route is accessible at: GET /masks/lookup?hostname={hostname}
+func Lookup(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		hostname, err := utils.NormalizeHostname(c.Query("hostname"))
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid hostname",
+			})
+		}
+		userID := c.Locals("user_id").(string)
+		masks := []mask{}
+		err = ctx.Instances().Gorm.Table("masks").Select(maskColumns).Where("masks.user_id = ? AND masks.used_on = ?", userID, hostname).Order("masks.created_at DESC").Find(&masks).Error
+		if err == nil {
+			err = loadRecipients(ctx.Instances().Gorm, masks)
+		}
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		return c.JSON(masks)
+	}
+}
@@ -0,0 +1,170 @@
+package masks
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// maxGenerateAttempts is the amount of names we try before giving up on finding an unused one.
+const maxGenerateAttempts = 10
+
+// Generate is used for creating a new mask with a name picked by the server.
+// The domain is optional, a random available domain is used when it is left empty.
+// This route is accessible at: POST /masks/generate
+func Generate(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Email          string   `json:"email"` // Deprecated: use emails instead.
+			Emails         []string `json:"emails"`
+			Domain         string   `json:"domain"`
+			Style          string   `json:"style"`
+			ExpiresAt      int64    `json:"expires_at"`
+			MaxReceived    int      `json:"max_received"`
+			DeleteOnExpiry bool     `json:"delete_on_expiry"`
+		}
+		err := json.Unmarshal(c.Body(), &body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+			})
+		}
+		if body.Email == "" && len(body.Emails) == 0 {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		if response := validateLimits(body.ExpiresAt, body.MaxReceived); response != nil {
+			return c.Status(400).JSON(response)
+		}
+		if body.Style == "" {
+			body.Style = utils.MaskStyleRandom
+		}
+		if body.Style != utils.MaskStyleRandom && body.Style != utils.MaskStyleWords && body.Style != utils.MaskStyleUUID {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid style",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+		entitlementsService := ctx.Instances().Entitlements
+
+		err = entitlementsService.CanCreateMask(userID)
+		if err != nil {
+			return entitlementsErrorResponse(c, err)
+		}
+
+		var domain *models.Domain
+		if body.Domain != "" {
+			domain, err = ctx.Instances().Domains.GetForUser(body.Domain, userID)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid domain",
+				})
+			}
+			err = entitlementsService.CanUseDomain(userID, domain)
+			if err != nil {
+				return entitlementsErrorResponse(c, err)
+			}
+		} else {
+			plan, err := entitlementsService.Plan(userID)
+			if err != nil {
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			availableDomains := make([]*models.Domain, 0)
+			for _, v := range ctx.Instances().Domains.Values() {
+				if entitlements.AllowsDomain(plan, v) == nil {
+					availableDomains = append(availableDomains, v)
+				}
+			}
+			if len(availableDomains) == 0 {
+				logrus.Error("no domains available for mask generation")
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			domain = availableDomains[rand.Intn(len(availableDomains))]
+		}
+
+		if body.Email != "" {
+			body.Emails = append(body.Emails, body.Email)
+		}
+		emails, err := findRecipients(db, userID, body.Emails)
+		if err != nil {
+			return recipientsErrorResponse(c, err)
+		}
+
+		for i := 0; i < maxGenerateAttempts; i++ {
+			name, err := utils.GenerateMaskName(body.Style)
+			if err != nil {
+				logrus.Errorf("mask generation error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+
+			var result struct {
+				Found bool
+			}
+			fullEmail := strings.ToLower(name + "@" + domain.Domain)
+			err = db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ?) OR EXISTS(SELECT 1 FROM reserved_masks WHERE mask = ?) AS found",
+				fullEmail, fullEmail).Scan(&result).Error
+			if err != nil {
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			if result.Found {
+				continue
+			}
+
+			maskRecord := &models.Mask{
+				Mask:           fullEmail,
+				Enabled:        true,
+				UserID:         userID,
+				Recipients:     toRecipients(fullEmail, emails),
+				ExpiresAt:      body.ExpiresAt,
+				MaxReceived:    body.MaxReceived,
+				DeleteOnExpiry: body.DeleteOnExpiry,
+			}
+			err = db.Create(maskRecord).Error
+			if err != nil {
+				// Another request might have claimed the same name in the meantime.
+				if strings.Contains(err.Error(), "(SQLSTATE 23505)") {
+					continue
+				}
+				logrus.Errorf("db error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			return c.JSON(maskRecord)
+		}
+
+		logrus.Errorf("failed to generate an unused mask on %v after %v attempts", domain.Domain, maxGenerateAttempts)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Could not generate a mask, try again",
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package masks
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/entitlements"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// validateLimits checks the expiry time and maximum amount of received messages of a mask, 0 disables either limit.
+// A response is returned when the values are invalid.
+func validateLimits(expiresAt int64, maxReceived int) *models.APIResponse {
+	if expiresAt < 0 || (expiresAt > 0 && expiresAt <= time.Now().Unix()) {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Expiry time has to be in the future",
+		}
+	}
+	if maxReceived < 0 {
+		return &models.APIResponse{
+			Success: false,
+			Message: "Invalid maximum amount of messages",
+		}
+	}
+	return nil
+}
+
+// entitlementsErrorResponse writes the response for an error returned by the entitlements of the user.
+func entitlementsErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case entitlements.ErrMaskLimit:
+		return c.Status(403).JSON(&models.APIResponse{
+			Success: false,
+			Message: "You have reached the maximum amount of masks of your plan",
+		})
+	case entitlements.ErrPremiumDomain:
+		return c.Status(403).JSON(&models.APIResponse{
+			Success: false,
+			Message: "That domain requires a premium plan",
+		})
+	}
+	logrus.Errorf("db error: %v", err)
+	return c.Status(500).JSON(&models.APIResponse{
+		Success: false,
+		Message: "Something went wrong",
+	})
+}
@@ -3,7 +3,10 @@ package masks
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/maskrapp/api/internal/global"
@@ -14,27 +17,159 @@ import (
 )
 
 type mask struct {
-	Mask              string `json:"mask"`
-	Email             string `json:"email"`
-	Enabled           bool   `json:"enabled"`
-	MessagesReceived  int    `json:"messages_received"`
-	MessagesForwarded int    `json:"messages_forwarded"`
+	Mask              string    `json:"mask"`
+	Emails            []string  `json:"emails" gorm:"-"`
+	Enabled           bool      `json:"enabled"`
+	MessagesReceived  int       `json:"messages_received"`
+	MessagesForwarded int       `json:"messages_forwarded"`
+	Label             string    `json:"label"`
+	Note              string    `json:"note"`
+	UsedOn            string    `json:"used_on"`
+	ExpiresAt         int64     `json:"expires_at"`
+	MaxReceived       int       `json:"max_received"`
+	DeleteOnExpiry    bool      `json:"delete_on_expiry"`
+	Expired           bool      `json:"expired"`
+	CreatedAt         time.Time `json:"-"`
 }
 
+// maskColumns are the columns that are selected into the mask struct.
+const maskColumns = "masks.mask, masks.enabled, masks.messages_forwarded, masks.messages_received, masks.label, masks.note, masks.used_on, masks.expires_at, masks.max_received, masks.delete_on_expiry, masks.created_at, " +
+	"((masks.expires_at > 0 AND masks.expires_at <= EXTRACT(EPOCH FROM NOW())) OR (masks.max_received > 0 AND masks.messages_received >= masks.max_received)) AS expired"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
 // Get is used for retrieving the user's masks.
+// The result is paginated, the `next_cursor` value of the response can be passed as the `cursor` query parameter to retrieve the next page.
+// Supported query parameters: cursor, limit, enabled, email, domain, search, sort (created_at, messages_received or messages_forwarded) and order (asc or desc).
 // This route is accessible at: GET /masks
 func Get(ctx global.Context) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		userID := c.Locals("user_id").(string)
+
+		limit := c.QueryInt("limit", defaultPageSize)
+		if limit < 1 || limit > maxPageSize {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid limit",
+			})
+		}
+
+		sort := c.Query("sort", "created_at")
+		if sort != "created_at" && sort != "messages_received" && sort != "messages_forwarded" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid sort",
+			})
+		}
+		order := strings.ToLower(c.Query("order", "desc"))
+		if order != "asc" && order != "desc" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid order",
+			})
+		}
+
+		var enabled *bool
+		if value := c.Query("enabled"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid enabled filter",
+				})
+			}
+			enabled = &parsed
+		}
+		email := c.Query("email")
+		domain := strings.ToLower(c.Query("domain"))
+		search := c.Query("search")
+
+		// filtered creates a fresh query every time, gorm statements can't be reused after they have been executed.
+		filtered := func() *gorm.DB {
+			query := ctx.Instances().Gorm.Table("masks").Where("masks.user_id = ?", userID)
+			if enabled != nil {
+				query = query.Where("masks.enabled = ?", *enabled)
+			}
+			if email != "" {
+				query = query.Where("EXISTS (SELECT 1 FROM mask_recipients INNER JOIN emails ON emails.id = mask_recipients.email_id WHERE mask_recipients.mask_address = masks.mask AND emails.email = ?)", email)
+			}
+			if domain != "" {
+				query = query.Where("masks.mask LIKE ?", "%@"+escapeLike(domain))
+			}
+			if search != "" {
+				pattern := "%" + escapeLike(search) + "%"
+				query = query.Where("(masks.mask ILIKE ? OR masks.label ILIKE ?)", pattern, pattern)
+			}
+			return query
+		}
+
+		var total int64
+		err := filtered().Count(&total).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+
+		query := filtered()
+		if value := c.Query("cursor"); value != "" {
+			cursor, err := decodeCursor(value)
+			if err != nil || cursor.Sort != sort {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Invalid cursor",
+				})
+			}
+			operator := "<"
+			if order == "asc" {
+				operator = ">"
+			}
+			query = query.Where(fmt.Sprintf("(masks.%v, masks.mask) %v (?, ?)", sort, operator), cursor.value(), cursor.Mask)
+		}
+
 		masks := []mask{}
-		err := ctx.Instances().Gorm.Table("masks").Select("masks.mask, masks.enabled, masks.messages_forwarded, masks.messages_received, emails.email").Joins("inner join emails on emails.id = masks.forward_to").Where("emails.user_id = ?", userID).Order("masks.created_at DESC").Find(&masks).Error
+		err = query.Select(maskColumns).Order(fmt.Sprintf("masks.%v %v, masks.mask %v", sort, order, order)).Limit(limit + 1).Find(&masks).Error
 		if err != nil {
+			logrus.Errorf("db error: %v", err)
 			return c.Status(500).JSON(&models.APIResponse{
 				Success: false,
 				Message: "Something went wrong!",
 			})
 		}
-		return c.JSON(masks)
+
+		// We fetch one extra row to find out whether there is another page.
+		var nextCursor string
+		if len(masks) > limit {
+			masks = masks[:limit]
+			nextCursor, err = newCursor(sort, masks[limit-1]).encode()
+			if err != nil {
+				logrus.Errorf("cursor error: %v", err)
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong!",
+				})
+			}
+		}
+
+		err = loadRecipients(ctx.Instances().Gorm, masks)
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"masks":       masks,
+			"total":       total,
+			"next_cursor": nextCursor,
+		})
 	}
 }
 
@@ -43,9 +178,13 @@ func Get(ctx global.Context) func(*fiber.Ctx) error {
 func Add(ctx global.Context) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		var body struct {
-			Name   string `json:"name"`
-			Email  string `json:"email"`
-			Domain string `json:"domain"`
+			Name           string   `json:"name"`
+			Email          string   `json:"email"` // Deprecated: use emails instead.
+			Emails         []string `json:"emails"`
+			Domain         string   `json:"domain"`
+			ExpiresAt      int64    `json:"expires_at"`
+			MaxReceived    int      `json:"max_received"`
+			DeleteOnExpiry bool     `json:"delete_on_expiry"`
 		}
 		err := json.Unmarshal(c.Body(), &body)
 		if err != nil {
@@ -53,7 +192,7 @@ func Add(ctx global.Context) func(*fiber.Ctx) error {
 				Success: false,
 			})
 		}
-		if body.Email == "" || body.Domain == "" || body.Name == "" {
+		if (body.Email == "" && len(body.Emails) == 0) || body.Domain == "" || body.Name == "" {
 			return c.Status(400).JSON(&models.APIResponse{
 				Success: false,
 				Message: "Invalid body",
@@ -66,17 +205,33 @@ func Add(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Invalid email address",
 			})
 		}
+		if strings.HasPrefix(fullEmail, utils.ReverseAliasPrefix) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That name is reserved",
+			})
+		}
+		if response := validateLimits(body.ExpiresAt, body.MaxReceived); response != nil {
+			return c.Status(400).JSON(response)
+		}
+
+		userID := c.Locals("user_id").(string)
 
-		_, err = ctx.Instances().Domains.Get(body.Domain)
-		//TODO: check if user can use the domain with their plan
+		domain, err := ctx.Instances().Domains.GetForUser(body.Domain, userID)
 		if err != nil {
 			return c.Status(400).JSON(&models.APIResponse{
 				Success: false,
 				Message: "Invalid domain",
 			})
 		}
-
-		userID := c.Locals("user_id").(string)
+		err = ctx.Instances().Entitlements.CanUseDomain(userID, domain)
+		if err != nil {
+			return entitlementsErrorResponse(c, err)
+		}
+		err = ctx.Instances().Entitlements.CanCreateMask(userID)
+		if err != nil {
+			return entitlementsErrorResponse(c, err)
+		}
 
 		var result struct {
 			Found bool
@@ -84,42 +239,30 @@ func Add(ctx global.Context) func(*fiber.Ctx) error {
 
 		db := ctx.Instances().Gorm
 
-		db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ?) AS found",
-			fullEmail).Scan(&result)
+		db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ?) OR EXISTS(SELECT 1 FROM reserved_masks WHERE mask = ?) AS found",
+			fullEmail, fullEmail).Scan(&result)
 		if result.Found {
 			return c.Status(400).JSON(&models.APIResponse{
 				Success: false,
 				Message: "That mask already exists",
 			})
 		}
-		emailRecord := &models.Email{}
-
-		err = db.Find(emailRecord, "email = ? AND user_id = ?", body.Email, userID).Error
+		if body.Email != "" {
+			body.Emails = append(body.Emails, body.Email)
+		}
+		emails, err := findRecipients(db, userID, body.Emails)
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return c.Status(400).JSON(&models.APIResponse{
-					Success: false,
-					Message: "You don't own that email",
-				})
-			}
-			logrus.Errorf("db error: %v", err)
-			return c.Status(500).JSON(&models.APIResponse{
-				Success: false,
-				Message: "Something went wrong",
-			})
+			return recipientsErrorResponse(c, err)
 		}
 
-		if !emailRecord.IsVerified {
-			return c.Status(400).JSON(&models.APIResponse{
-				Success: false,
-				Message: "Email is not verified",
-			})
-		}
 		maskRecord := &models.Mask{
-			Mask:      fullEmail,
-			Enabled:   true,
-			ForwardTo: emailRecord.Id,
-			UserID:    userID,
+			Mask:           fullEmail,
+			Enabled:        true,
+			UserID:         userID,
+			Recipients:     toRecipients(fullEmail, emails),
+			ExpiresAt:      body.ExpiresAt,
+			MaxReceived:    body.MaxReceived,
+			DeleteOnExpiry: body.DeleteOnExpiry,
 		}
 
 		err = db.Create(&maskRecord).Error
@@ -0,0 +1,205 @@
+package masks
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxRecipients is the maximum amount of emails a single mask can forward to.
+const maxRecipients = 10
+
+var (
+	errNoRecipients        = errors.New("no recipients")
+	errTooManyRecipients   = errors.New("too many recipients")
+	errRecipientNotOwned   = errors.New("recipient not owned")
+	errRecipientUnverified = errors.New("recipient not verified")
+)
+
+// findRecipients looks up the given email addresses, every address has to be a verified email of the user.
+// Addresses are compared case-insensitively.
+func findRecipients(db *gorm.DB, userID string, addresses []string) ([]*models.Email, error) {
+	unique := make([]string, 0, len(addresses))
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		unique = append(unique, address)
+	}
+	if len(unique) == 0 {
+		return nil, errNoRecipients
+	}
+	if len(unique) > maxRecipients {
+		return nil, errTooManyRecipients
+	}
+
+	records := make([]*models.Email, 0)
+	err := db.Order("is_verified DESC, id ASC").Find(&records, "user_id = ? AND lower(email) IN ?", userID, unique).Error
+	if err != nil {
+		return nil, err
+	}
+	// The same address can be stored with different casing, the verified record is preferred.
+	emails := make([]*models.Email, 0, len(unique))
+	found := make(map[string]bool)
+	for _, record := range records {
+		address := strings.ToLower(record.Email)
+		if found[address] {
+			continue
+		}
+		found[address] = true
+		emails = append(emails, record)
+	}
+	if len(emails) != len(unique) {
+		return nil, errRecipientNotOwned
+	}
+	for _, email := range emails {
+		if !email.IsVerified {
+			return nil, errRecipientUnverified
+		}
+	}
+	return emails, nil
+}
+
+// recipientsErrorResponse creates the response for errors returned by findRecipients.
+func recipientsErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case errNoRecipients:
+		return c.Status(400).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Invalid body",
+		})
+	case errTooManyRecipients:
+		return c.Status(400).JSON(&models.APIResponse{
+			Success: false,
+			Message: "A mask can't forward to that many emails",
+		})
+	case errRecipientNotOwned:
+		return c.Status(400).JSON(&models.APIResponse{
+			Success: false,
+			Message: "You don't own that email",
+		})
+	case errRecipientUnverified:
+		return c.Status(400).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Email is not verified",
+		})
+	}
+	logrus.Errorf("db error: %v", err)
+	return c.Status(500).JSON(&models.APIResponse{
+		Success: false,
+		Message: "Something went wrong",
+	})
+}
+
+// toRecipients converts emails to recipient records of the given mask.
+func toRecipients(mask string, emails []*models.Email) []models.MaskRecipient {
+	recipients := make([]models.MaskRecipient, 0, len(emails))
+	for _, email := range emails {
+		recipients = append(recipients, models.MaskRecipient{MaskAddress: mask, EmailID: email.Id})
+	}
+	return recipients
+}
+
+// loadRecipients fills in the email addresses that the given masks forward to.
+func loadRecipients(db *gorm.DB, masks []mask) error {
+	if len(masks) == 0 {
+		return nil
+	}
+	addresses := make([]string, 0, len(masks))
+	for _, m := range masks {
+		addresses = append(addresses, m.Mask)
+	}
+	var rows []struct {
+		MaskAddress string
+		Email       string
+	}
+	err := db.Table("mask_recipients").Select("mask_recipients.mask_address, emails.email").Joins("inner join emails on emails.id = mask_recipients.email_id").Where("mask_recipients.mask_address IN ?", addresses).Order("mask_recipients.created_at ASC").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	recipients := make(map[string][]string)
+	for _, row := range rows {
+		recipients[row.MaskAddress] = append(recipients[row.MaskAddress], row.Email)
+	}
+	for i := range masks {
+		masks[i].Emails = recipients[masks[i].Mask]
+		if masks[i].Emails == nil {
+			masks[i].Emails = []string{}
+		}
+	}
+	return nil
+}
+
+// UpdateRecipients is used for replacing the emails that a mask forwards to.
+// This route is accessible at: PUT /masks/{mask}/recipients
+func UpdateRecipients(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Emails []string `json:"emails"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		mask := c.Params("mask")
+		if mask == "" {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Missing mask parameter",
+			})
+		}
+
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		maskRecord := &models.Mask{}
+		err = db.First(maskRecord, "mask = ? AND user_id = ?", mask, userID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "You don't own that mask",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+
+		emails, err := findRecipients(db, userID, body.Emails)
+		if err != nil {
+			return recipientsErrorResponse(c, err)
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Delete(&models.MaskRecipient{}, "mask_address = ?", maskRecord.Mask).Error
+			if err != nil {
+				return err
+			}
+			return tx.Create(toRecipients(maskRecord.Mask, emails)).Error
+		})
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package masks
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/rules"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxRules is the maximum amount of sender rules a single mask can have.
+const maxRules = 100
+
+// GetRules is used for retrieving the sender rules of a mask.
+// This route is accessible at: GET /masks/{mask}/rules
+func GetRules(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		mask := c.Params("mask")
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		var result struct {
+			Found bool
+		}
+		err := db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ? AND user_id = ?) AS found",
+			mask, userID).Scan(&result).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		if !result.Found {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that mask",
+			})
+		}
+
+		maskRules := make([]*models.MaskRule, 0)
+		err = db.Where("mask_address = ?", mask).Order("created_at ASC").Find(&maskRules).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		return c.JSON(maskRules)
+	}
+}
+
+// AddRule is used for creating a sender rule on a mask.
+// This route is accessible at: POST /masks/{mask}/rules
+func AddRule(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Type    string `json:"type"`
+			Pattern string `json:"pattern"`
+			Action  string `json:"action"`
+		}
+		err := c.BodyParser(&body)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid body",
+			})
+		}
+		if !rules.IsValidAction(body.Action) {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid action",
+			})
+		}
+		pattern, err := rules.Normalize(body.Type, body.Pattern)
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid pattern",
+			})
+		}
+
+		mask := c.Params("mask")
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		var result struct {
+			Found bool
+		}
+		err = db.Raw("SELECT EXISTS(SELECT 1 FROM masks WHERE mask = ? AND user_id = ?) AS found",
+			mask, userID).Scan(&result).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if !result.Found {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "You don't own that mask",
+			})
+		}
+
+		var count int64
+		err = db.Model(&models.MaskRule{}).Where("mask_address = ?", mask).Count(&count).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		if count >= maxRules {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "That mask has too many rules",
+			})
+		}
+
+		rule := &models.MaskRule{
+			MaskAddress: mask,
+			Type:        body.Type,
+			Pattern:     pattern,
+			Action:      body.Action,
+		}
+		err = db.Create(rule).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong",
+			})
+		}
+		return c.JSON(rule)
+	}
+}
+
+// DeleteRule is used for deleting a sender rule of a mask.
+// This route is accessible at: DELETE /masks/{mask}/rules/{id}
+func DeleteRule(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(400).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Invalid parameters",
+			})
+		}
+		mask := c.Params("mask")
+		userID := c.Locals("user_id").(string)
+		db := ctx.Instances().Gorm
+
+		rule := &models.MaskRule{}
+		err = db.Table("mask_rules").Select("mask_rules.*").Joins("inner join masks on masks.mask = mask_rules.mask_address").Where("mask_rules.id = ? AND mask_rules.mask_address = ? AND masks.user_id = ?", id, mask, userID).First(rule).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Rule not found",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+
+		err = db.Delete(&models.MaskRule{}, "id = ?", rule.ID).Error
+		if err != nil {
+			logrus.Errorf("db error: %v", err)
+			return c.Status(500).JSON(&models.APIResponse{
+				Success: false,
+				Message: "Something went wrong!",
+			})
+		}
+		return c.JSON(&models.APIResponse{
+			Success: true,
+			Message: "Rule deleted",
+		})
+	}
+}
@@ -31,6 +31,30 @@ func Status(ctx global.Context) func(*fiber.Ctx) error {
 			})
 		}
 		userID := c.Locals("user_id").(string)
+
+		if body.Value {
+			maskRecord := &models.Mask{}
+			err = ctx.Instances().Gorm.First(maskRecord, "mask = ? and user_id = ?", mask, userID).Error
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return c.Status(400).JSON(&models.APIResponse{
+						Success: false,
+						Message: "You don't own that mask",
+					})
+				}
+				return c.Status(500).JSON(&models.APIResponse{
+					Success: false,
+					Message: "Something went wrong",
+				})
+			}
+			if maskRecord.Expired() {
+				return c.Status(400).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That mask has expired",
+				})
+			}
+		}
+
 		values := map[string]interface{}{
 			"enabled": body.Value,
 		}
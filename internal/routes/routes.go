@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/maskrapp/api/internal/apikeys"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/middleware"
 	"github.com/maskrapp/api/internal/routes/account"
@@ -14,6 +15,7 @@ import (
 	"github.com/maskrapp/api/internal/routes/emails"
 	"github.com/maskrapp/api/internal/routes/masks"
 	"github.com/maskrapp/api/internal/routes/token"
+	"github.com/maskrapp/api/internal/routes/wellknown"
 )
 
 func Setup(ctx global.Context, app *fiber.App) {
@@ -30,6 +32,9 @@ func Setup(ctx global.Context, app *fiber.App) {
 		return c.JSON(c.GetReqHeaders())
 	})
 
+	app.Get("/.well-known/jwks.json", wellknown.JWKS(ctx))
+	app.Get("/export/:file", middleware.IPRateLimit(ctx, 10, time.Minute, account.DownloadExport(ctx)))
+
 	signupGroup := app.Group("/auth/signup")
 
 	signupGroup.Post("/", middleware.EmailRateLimit(ctx, 3, time.Minute, auth.Signup(ctx)))
@@ -38,8 +43,14 @@ func Setup(ctx global.Context, app *fiber.App) {
 	signupGroup.Post("/create", middleware.EmailRateLimit(ctx, 5, time.Minute, auth.Create(ctx)))
 
 	signinGroup := app.Group("/auth/signin")
-	signinGroup.Post("/google", signin.Google(ctx))
 	signinGroup.Post("/email", middleware.EmailRateLimit(ctx, 7, time.Minute, signin.Email(ctx)))
+	signinGroup.Post("/magic", middleware.EmailRateLimit(ctx, 3, 5*time.Minute, signin.Magic(ctx)))
+	signinGroup.Post("/magic/redeem", middleware.EmailRateLimit(ctx, 5, 5*time.Minute, signin.RedeemMagic(ctx)))
+	signinGroup.Post("/mfa", middleware.IPRateLimit(ctx, 10, time.Minute, signin.MFA(ctx)))
+	signinGroup.Post("/webauthn/options", middleware.IPRateLimit(ctx, 10, time.Minute, signin.WebAuthnOptions(ctx)))
+	signinGroup.Post("/webauthn", middleware.IPRateLimit(ctx, 10, time.Minute, signin.WebAuthn(ctx)))
+	// Registered last, so that it doesn't shadow the routes above.
+	signinGroup.Post("/:provider", signin.Provider(ctx))
 
 	resetPasswordGroup := app.Group("/auth/reset-password")
 	resetPasswordGroup.Post("/", middleware.EmailRateLimit(ctx, 5, 5*time.Minute, auth.Reset(ctx)))
@@ -48,6 +59,7 @@ func Setup(ctx global.Context, app *fiber.App) {
 
 	emailsGroup := app.Group("/emails")
 	emailsGroup.Use(middleware.AuthMiddleware(ctx))
+	emailsGroup.Use(middleware.Scopes(apikeys.EmailsRead, apikeys.EmailsWrite))
 	emailsGroup.Get("/", middleware.UserRateLimit(ctx, 30, time.Minute, emails.Get(ctx)))
 	emailsGroup.Post("/new", middleware.UserRateLimit(ctx, 5, time.Minute, emails.Add(ctx)))
 	emailsGroup.Delete("/:email", middleware.UserRateLimit(ctx, 15, time.Minute, emails.Delete(ctx)))
@@ -56,18 +68,56 @@ func Setup(ctx global.Context, app *fiber.App) {
 
 	masksGroup := app.Group("/masks")
 	masksGroup.Use(middleware.AuthMiddleware(ctx))
+	masksGroup.Use(middleware.Scopes(apikeys.MasksRead, apikeys.MasksWrite))
 	masksGroup.Get("/", middleware.UserRateLimit(ctx, 30, time.Minute, masks.Get(ctx)))
+	masksGroup.Get("/lookup", middleware.UserRateLimit(ctx, 30, time.Minute, masks.Lookup(ctx)))
 	masksGroup.Post("/new", middleware.UserRateLimit(ctx, 5, time.Minute, masks.Add(ctx)))
+	masksGroup.Post("/generate", middleware.UserRateLimit(ctx, 5, time.Minute, masks.Generate(ctx)))
 	masksGroup.Delete("/:mask", middleware.UserRateLimit(ctx, 15, time.Minute, masks.Delete(ctx)))
+	masksGroup.Patch("/:mask", middleware.UserRateLimit(ctx, 15, time.Minute, masks.Update(ctx)))
 	masksGroup.Put("/:mask/status", middleware.UserRateLimit(ctx, 15, time.Minute, masks.Status(ctx)))
+	masksGroup.Put("/:mask/recipients", middleware.UserRateLimit(ctx, 15, time.Minute, masks.UpdateRecipients(ctx)))
+	masksGroup.Get("/:mask/rules", middleware.UserRateLimit(ctx, 30, time.Minute, masks.GetRules(ctx)))
+	masksGroup.Post("/:mask/rules", middleware.UserRateLimit(ctx, 15, time.Minute, masks.AddRule(ctx)))
+	masksGroup.Delete("/:mask/rules/:id", middleware.UserRateLimit(ctx, 15, time.Minute, masks.DeleteRule(ctx)))
 
 	domainsGroup := app.Group("/domains")
 	domainsGroup.Use(middleware.AuthMiddleware(ctx))
+	domainsGroup.Use(middleware.Scopes(apikeys.DomainsRead, apikeys.DomainsWrite))
 	domainsGroup.Get("/", middleware.UserRateLimit(ctx, 30, time.Minute, domains.Get(ctx)))
+	domainsGroup.Get("/custom", middleware.UserRateLimit(ctx, 30, time.Minute, domains.GetCustom(ctx)))
+	domainsGroup.Post("/custom", middleware.UserRateLimit(ctx, 5, time.Minute, domains.AddCustom(ctx)))
+	domainsGroup.Post("/custom/:domain/verify", middleware.UserRateLimit(ctx, 5, time.Minute, domains.VerifyCustom(ctx)))
+	domainsGroup.Put("/custom/:domain/catch-all", middleware.UserRateLimit(ctx, 15, time.Minute, domains.CatchAll(ctx)))
+	domainsGroup.Delete("/custom/:domain", middleware.UserRateLimit(ctx, 5, time.Minute, domains.DeleteCustom(ctx)))
 
 	accountGroup := app.Group("/account")
 	accountGroup.Use(middleware.AuthMiddleware(ctx))
+	accountGroup.Use(middleware.NoAPIKeys)
 	accountGroup.Get("/", account.Get(ctx))
+	accountGroup.Delete("/", middleware.UserRateLimit(ctx, 5, time.Minute, account.Delete(ctx)))
+	accountGroup.Put("/email", middleware.UserRateLimit(ctx, 5, time.Minute, account.ChangePrimaryEmail(ctx)))
+	accountGroup.Put("/password", middleware.UserRateLimit(ctx, 5, time.Minute, account.ChangePassword(ctx)))
+	accountGroup.Post("/export", middleware.UserRateLimit(ctx, 3, time.Hour, account.Export(ctx)))
+	accountGroup.Post("/2fa/totp", middleware.UserRateLimit(ctx, 5, time.Minute, account.EnrollTOTP(ctx)))
+	accountGroup.Post("/2fa/totp/confirm", middleware.UserRateLimit(ctx, 5, time.Minute, account.ConfirmTOTP(ctx)))
+	accountGroup.Post("/2fa/totp/re-enroll", middleware.UserRateLimit(ctx, 5, time.Minute, account.ReenrollTOTP(ctx)))
+	accountGroup.Delete("/2fa/totp", middleware.UserRateLimit(ctx, 5, time.Minute, account.DisableTOTP(ctx)))
+	accountGroup.Get("/2fa/recovery-codes", middleware.UserRateLimit(ctx, 30, time.Minute, account.GetRecoveryCodes(ctx)))
+	accountGroup.Post("/2fa/recovery-codes", middleware.UserRateLimit(ctx, 5, time.Minute, account.RegenerateRecoveryCodes(ctx)))
+	accountGroup.Get("/sessions", middleware.UserRateLimit(ctx, 30, time.Minute, account.GetSessions(ctx)))
+	accountGroup.Delete("/sessions", middleware.UserRateLimit(ctx, 5, time.Minute, account.RevokeOtherSessions(ctx)))
+	accountGroup.Delete("/sessions/:id", middleware.UserRateLimit(ctx, 15, time.Minute, account.RevokeSession(ctx)))
+	accountGroup.Get("/providers", middleware.UserRateLimit(ctx, 30, time.Minute, account.GetProviders(ctx)))
+	accountGroup.Post("/providers/:provider", middleware.UserRateLimit(ctx, 5, time.Minute, account.LinkProvider(ctx)))
+	accountGroup.Delete("/providers/:provider", middleware.UserRateLimit(ctx, 5, time.Minute, account.UnlinkProvider(ctx)))
+	accountGroup.Get("/passkeys", middleware.UserRateLimit(ctx, 30, time.Minute, account.GetPasskeys(ctx)))
+	accountGroup.Post("/passkeys/options", middleware.UserRateLimit(ctx, 5, time.Minute, account.PasskeyOptions(ctx)))
+	accountGroup.Post("/passkeys", middleware.UserRateLimit(ctx, 5, time.Minute, account.AddPasskey(ctx)))
+	accountGroup.Delete("/passkeys/:id", middleware.UserRateLimit(ctx, 15, time.Minute, account.DeletePasskey(ctx)))
+	accountGroup.Get("/api-keys", middleware.UserRateLimit(ctx, 30, time.Minute, account.GetAPIKeys(ctx)))
+	accountGroup.Post("/api-keys", middleware.UserRateLimit(ctx, 5, time.Minute, account.CreateAPIKey(ctx)))
+	accountGroup.Delete("/api-keys/:id", middleware.UserRateLimit(ctx, 15, time.Minute, account.DeleteAPIKey(ctx)))
 
 	tokenGroup := app.Group("/token")
 	tokenGroup.Post("/refresh", token.Refresh(ctx))
@@ -9,14 +9,15 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/maskrapp/api/internal/global"
 	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/sessions"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // Refresh is used for refreshing access token, this is done by providing the refresh token.
+// The refresh token is rotated, the response is a new token pair and the presented refresh token can't be used again.
 // This endpoint is accessible at: POST /token/refresh
 func Refresh(ctx global.Context) func(*fiber.Ctx) error {
-	//TODO: needs hardening
 	return func(c *fiber.Ctx) error {
 		body := make(map[string]string)
 		err := json.Unmarshal(c.Body(), &body)
@@ -34,7 +35,7 @@ func Refresh(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Invalid token",
 			})
 		}
-		key := fmt.Sprintf("blacklist:%v", refreshToken)
+		key := fmt.Sprintf("rt-blacklist:%v", refreshToken)
 		err = ctx.Instances().Redis.Get(ctx, key).Err()
 		if err == nil {
 			return c.Status(401).JSON(&models.APIResponse{
@@ -68,17 +69,56 @@ func Refresh(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Token version mismatch",
 			})
 		}
-		jwt, err := ctx.Instances().JWT.GenerateAccessToken(claims.UserId, tokenVersion, claims.Provider)
+		// Refresh tokens that were issued before sessions existed are exchanged once for a new session.
+		if claims.Session == "" {
+			return migrateLegacyToken(ctx, c, user, refreshToken, claims.Provider, claims.ExpiresAt)
+		}
+		pair, err := ctx.Instances().Sessions.Rotate(c, claims)
 		if err != nil {
+			if err == sessions.ErrRevoked || err == sessions.ErrReused {
+				return c.Status(401).JSON(&models.APIResponse{
+					Success: false,
+					Message: "That session has been revoked",
+				})
+			}
+			logrus.Errorf("db error: %v", err)
 			return c.Status(500).JSON(&models.APIResponse{
 				Success: false,
 				Message: "Something went wrong",
 			})
 		}
-		return c.JSON(jwt)
+		return c.JSON(pair)
 	}
 }
 
+// migrateLegacyToken creates a session for a refresh token without one, the token is blacklisted so it can only be exchanged once.
+func migrateLegacyToken(ctx global.Context, c *fiber.Ctx, user *models.User, refreshToken, provider string, expiresAt int64) error {
+	key := fmt.Sprintf("rt-blacklist:%v", refreshToken)
+	first, err := ctx.Instances().Redis.SetNX(c.Context(), key, 1, time.Until(time.Unix(expiresAt, 0))).Result()
+	if err != nil {
+		logrus.Error("redis error(refresh-token): ", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	if !first {
+		return c.Status(401).JSON(&models.APIResponse{
+			Success: false,
+			Message: "That token is blacklisted",
+		})
+	}
+	pair, err := ctx.Instances().Sessions.Create(c, user, provider)
+	if err != nil {
+		logrus.Errorf("db error: %v", err)
+		return c.Status(500).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Something went wrong",
+		})
+	}
+	return c.JSON(pair)
+}
+
 // Revoke is used for revoking a refresh token, the token is temporarily stored in a redis db.
 // This endpoint is accessible at: POST /token/revoke
 func Revoke(ctx global.Context) func(*fiber.Ctx) error {
@@ -97,6 +137,11 @@ func Revoke(ctx global.Context) func(*fiber.Ctx) error {
 				Message: "Invalid token",
 			})
 		}
+		// Revoking a refresh token signs its session out.
+		_, err = ctx.Instances().Sessions.Revoke(claims.UserId, claims.Session)
+		if err != nil {
+			logrus.Error("db err(revoke-token): ", err)
+		}
 		key := fmt.Sprintf("rt-blacklist:%v", body.Token)
 		cmd := ctx.Instances().Redis.Get(c.Context(), key)
 		err = cmd.Err()
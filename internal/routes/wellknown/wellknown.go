@@ -0,0 +1,15 @@
+package wellknown
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/global"
+)
+
+// JWKS responds with the public keys that verify the tokens of the API, so that other services don't need a shared secret.
+// This endpoint is accessible at GET /.well-known/jwks.json
+func JWKS(ctx global.Context) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(fiber.Map{"keys": ctx.Instances().JWT.JWKS()})
+	}
+}
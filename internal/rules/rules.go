@@ -0,0 +1,122 @@
+package rules
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/utils"
+)
+
+const (
+	TypeAddress  = "address"  // Matches a single sender address.
+	TypeDomain   = "domain"   // Matches every sender of a domain, including its subdomains.
+	TypeWildcard = "wildcard" // Matches sender addresses against a pattern where `*` matches anything, e.g. `news*@*.example.com`.
+)
+
+const (
+	ActionAllow  = "allow"  // Forward the message.
+	ActionBlock  = "block"  // Silently drop the message.
+	ActionReject = "reject" // Reject the message, so the sender gets a bounce.
+)
+
+// Normalize validates the pattern of a rule and returns it in the form it is stored and matched in.
+func Normalize(ruleType, pattern string) (string, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	switch ruleType {
+	case TypeAddress:
+		if !utils.EmailRegex.MatchString(pattern) {
+			return "", errors.New("invalid address")
+		}
+	case TypeDomain:
+		pattern = strings.TrimPrefix(pattern, "@")
+		if !utils.HostnameRegex.MatchString(pattern) {
+			return "", errors.New("invalid domain")
+		}
+	case TypeWildcard:
+		if pattern == "" || len(pattern) > 254 || strings.ContainsAny(pattern, " \t\r\n") {
+			return "", errors.New("invalid wildcard")
+		}
+	default:
+		return "", errors.New("unknown rule type")
+	}
+	return pattern, nil
+}
+
+// IsValidAction reports whether the given action is known.
+func IsValidAction(action string) bool {
+	return action == ActionAllow || action == ActionBlock || action == ActionReject
+}
+
+// Match reports whether the rule matches the sender address.
+func Match(rule *models.MaskRule, sender string) bool {
+	sender = strings.ToLower(sender)
+	switch rule.Type {
+	case TypeAddress:
+		return sender == rule.Pattern
+	case TypeDomain:
+		split := strings.Split(sender, "@")
+		if len(split) != 2 {
+			return false
+		}
+		return split[1] == rule.Pattern || strings.HasSuffix(split[1], "."+rule.Pattern)
+	case TypeWildcard:
+		expression := "^" + strings.ReplaceAll(regexp.QuoteMeta(rule.Pattern), "\\*", ".*") + "$"
+		matched, err := regexp.MatchString(expression, sender)
+		return err == nil && matched
+	}
+	return false
+}
+
+// Evaluate returns the action of the rule that decides what happens to mail from the sender, or an empty string when no rule matches.
+// Address rules take precedence over domain rules, which take precedence over wildcard rules. Between rules of the same type the longest pattern wins,
+// after that the most restrictive action.
+func Evaluate(rules []*models.MaskRule, sender string) string {
+	var best *models.MaskRule
+	for _, rule := range rules {
+		if !Match(rule, sender) {
+			continue
+		}
+		if best == nil || precedes(rule, best) {
+			best = rule
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Action
+}
+
+// precedes reports whether rule a takes precedence over rule b.
+func precedes(a, b *models.MaskRule) bool {
+	if typeRank(a) != typeRank(b) {
+		return typeRank(a) > typeRank(b)
+	}
+	// Wildcards don't add anything to how specific a pattern is.
+	lengthA, lengthB := len(strings.ReplaceAll(a.Pattern, "*", "")), len(strings.ReplaceAll(b.Pattern, "*", ""))
+	if lengthA != lengthB {
+		return lengthA > lengthB
+	}
+	return severity(a) > severity(b)
+}
+
+func typeRank(rule *models.MaskRule) int {
+	switch rule.Type {
+	case TypeAddress:
+		return 2
+	case TypeDomain:
+		return 1
+	}
+	return 0
+}
+
+func severity(rule *models.MaskRule) int {
+	switch rule.Action {
+	case ActionReject:
+		return 2
+	case ActionBlock:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package rules_test
+
+import (
+	"testing"
+
+	"github.com/maskrapp/api/internal/models"
+	"github.com/maskrapp/api/internal/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	pattern, err := rules.Normalize(rules.TypeDomain, "@Example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", pattern)
+
+	_, err = rules.Normalize(rules.TypeAddress, "not an address")
+	assert.NotNil(t, err)
+	_, err = rules.Normalize("unknown", "example.com")
+	assert.NotNil(t, err)
+}
+
+func TestEvaluate(t *testing.T) {
+	maskRules := []*models.MaskRule{
+		{Type: rules.TypeWildcard, Pattern: "*", Action: rules.ActionBlock},
+		{Type: rules.TypeDomain, Pattern: "example.com", Action: rules.ActionAllow},
+		{Type: rules.TypeAddress, Pattern: "spam@example.com", Action: rules.ActionReject},
+		{Type: rules.TypeWildcard, Pattern: "news*@*.shop.com", Action: rules.ActionAllow},
+		{Type: rules.TypeWildcard, Pattern: "*@deals.shop.com", Action: rules.ActionReject},
+	}
+	assert.Equal(t, rules.ActionAllow, rules.Evaluate(maskRules, "friend@example.com"))
+	assert.Equal(t, rules.ActionAllow, rules.Evaluate(maskRules, "friend@mail.example.com"))
+	assert.Equal(t, rules.ActionReject, rules.Evaluate(maskRules, "Spam@Example.com"))
+	assert.Equal(t, rules.ActionBlock, rules.Evaluate(maskRules, "someone@other.org"))
+	assert.Equal(t, rules.ActionAllow, rules.Evaluate(maskRules, "newsletter@eu.shop.com"))
+	assert.Equal(t, rules.ActionReject, rules.Evaluate(maskRules, "newsletter@deals.shop.com"))
+	assert.Equal(t, "", rules.Evaluate(nil, "someone@other.org"))
+}
@@ -0,0 +1,201 @@
+package sessions
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/maskrapp/api/internal/jwt"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRevoked is returned when a session doesn't exist anymore.
+	ErrRevoked = errors.New("session has been revoked")
+	// ErrReused is returned when a refresh token that has already been rotated is used again. The session is revoked when this happens.
+	ErrReused = errors.New("refresh token has already been used")
+)
+
+// EventRefreshTokenReuse is the type of the security event that is recorded when ErrReused is returned.
+const EventRefreshTokenReuse = "refresh_token_reuse"
+
+// maxDeviceName is the maximum length of the device name a client can send.
+const maxDeviceName = 64
+
+// Sessions records the devices that signed in, every token pair belongs to a session.
+type Sessions struct {
+	db         *gorm.DB
+	jwt        *jwt.JWTHandler
+	production bool
+}
+
+// New creates a new Sessions instance.
+func New(db *gorm.DB, jwtHandler *jwt.JWTHandler, production bool) *Sessions {
+	return &Sessions{db: db, jwt: jwtHandler, production: production}
+}
+
+// Create records a new session for the device that made the request, and creates its token pair.
+// Clients can name the device with the `X-Device-Name` header, otherwise the name is derived from the user agent.
+func (s *Sessions) Create(c *fiber.Ctx, user *models.User, provider string) (*jwt.Pair, error) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	deviceName := strings.TrimSpace(c.Get("X-Device-Name"))
+	if deviceName == "" {
+		deviceName = DeviceName(userAgent)
+	}
+	if len(deviceName) > maxDeviceName {
+		deviceName = deviceName[:maxDeviceName]
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	session := &models.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		Provider:   provider,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IP:         s.ip(c),
+		LastUsedAt: time.Now().Unix(),
+	}
+	err := s.db.Create(session).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.jwt.CreatePair(user.ID, user.TokenVersion, provider, session.ID, session.Generation)
+}
+
+// Reissue creates a new token pair for an existing session, used after the token version of the user changed.
+// The refresh token of the session is rotated.
+func (s *Sessions) Reissue(userID string, version int, provider, sessionID string) (*jwt.Pair, error) {
+	var generation []int
+	err := s.db.Raw("UPDATE sessions SET generation = generation + 1 WHERE id = ? AND user_id = ? RETURNING generation", sessionID, userID).Scan(&generation).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(generation) == 0 {
+		return nil, ErrRevoked
+	}
+	return s.jwt.CreatePair(userID, version, provider, sessionID, generation[0])
+}
+
+// Rotate exchanges a refresh token for a new token pair of the same session. The presented refresh token can't be used again.
+// Presenting a refresh token that was already rotated means it has been copied, the session is revoked and a security event is recorded.
+// Refresh tokens without a session are issued before sessions existed, they have to be exchanged with Create instead.
+func (s *Sessions) Rotate(c *fiber.Ctx, claims *jwt.UserClaims) (*jwt.Pair, error) {
+	if claims.Session == "" {
+		return nil, ErrRevoked
+	}
+	res := s.db.Model(&models.Session{}).Where("id = ? AND user_id = ? AND generation = ?", claims.Session, claims.UserId, claims.Generation).Updates(map[string]interface{}{
+		"generation":   claims.Generation + 1,
+		"last_used_at": time.Now().Unix(),
+		"ip":           s.ip(c),
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return s.jwt.CreatePair(claims.UserId, claims.Version, claims.Provider, claims.Session, claims.Generation+1)
+	}
+
+	revoked, err := s.Revoke(claims.UserId, claims.Session)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrRevoked
+	}
+	event := &models.SecurityEvent{
+		UserID:    claims.UserId,
+		Type:      EventRefreshTokenReuse,
+		IP:        s.ip(c),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+	logrus.WithFields(logrus.Fields{
+		"user_id":    event.UserID,
+		"session_id": claims.Session,
+		"ip":         event.IP,
+	}).Warn("refresh token reuse detected, session revoked")
+	err = s.db.Create(event).Error
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrReused
+}
+
+// List retrieves the sessions of the user, most recently used first.
+func (s *Sessions) List(userID string) ([]*models.Session, error) {
+	sessions := make([]*models.Session, 0)
+	err := s.db.Where("user_id = ?", userID).Order("last_used_at DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke deletes a session of the user, its refresh tokens can't be used anymore. False is returned when the session doesn't exist.
+func (s *Sessions) Revoke(userID, sessionID string) (bool, error) {
+	res := s.db.Delete(&models.Session{}, "id = ? AND user_id = ?", sessionID, userID)
+	return res.RowsAffected > 0, res.Error
+}
+
+// RevokeOthers deletes every session of the user except for the given one, and returns the amount of revoked sessions.
+func (s *Sessions) RevokeOthers(userID, keepID string) (int64, error) {
+	res := s.db.Delete(&models.Session{}, "user_id = ? AND id <> ?", userID, keepID)
+	return res.RowsAffected, res.Error
+}
+
+func (s *Sessions) ip(c *fiber.Ctx) string {
+	if s.production {
+		// The API runs behind a proxy that sets this header, see middleware.IPRateLimit.
+		return c.Get("X-Real-Ip")
+	}
+	return c.IP()
+}
+
+// DeviceName derives a short description of the device from its user agent, like "Firefox on Windows".
+func DeviceName(userAgent string) string {
+	browser := ""
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	systems := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+	for _, sys := range systems {
+		if strings.Contains(userAgent, sys.token) {
+			system = sys.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,121 @@
+package sessions_test
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/jwt"
+	"github.com/maskrapp/api/internal/sessions"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestDeviceName(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/115.0":                                                  "Firefox on Windows",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.5 Safari/605.1.15":             "Safari on macOS",
+		"Mozilla/5.0 (Linux; Android 13) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Mobile Safari/537.36":                      "Chrome on Android",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36 Edg/114.0.1823.67": "Edge on Windows",
+		"curl/8.0.1": "Unknown device",
+	}
+	for userAgent, expected := range tests {
+		assert.Equal(t, expected, sessions.DeviceName(userAgent), userAgent)
+	}
+}
+
+func newSessions(t *testing.T) (*sessions.Sessions, *jwt.JWTHandler, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtHandler := jwt.New("secret", nil, time.Minute, time.Hour)
+	return sessions.New(db, jwtHandler, false), jwtHandler, mock
+}
+
+// rotate calls Sessions.Rotate from within a request, since it reads the client of the request.
+func rotate(t *testing.T, s *sessions.Sessions, claims *jwt.UserClaims) (*jwt.Pair, error) {
+	var pair *jwt.Pair
+	var rotateErr error
+	app := fiber.New()
+	app.Post("/token/refresh", func(c *fiber.Ctx) error {
+		pair, rotateErr = s.Rotate(c, claims)
+		return nil
+	})
+	_, err := app.Test(httptest.NewRequest("POST", "/token/refresh", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair, rotateErr
+}
+
+func TestRotate(t *testing.T) {
+	s, jwtHandler, mock := newSessions(t)
+	claims := &jwt.UserClaims{UserId: "user", Version: 1, Provider: "email", Session: "session", Generation: 2}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET`)).
+		WithArgs(3, sqlmock.AnyArg(), sqlmock.AnyArg(), "session", "user", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	pair, err := rotate(t, s, claims)
+	assert.NoError(t, err)
+	if assert.NotNil(t, pair) {
+		refreshed, err := jwtHandler.Validate(pair.RefreshToken.Token, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "session", refreshed.Session)
+		assert.Equal(t, 3, refreshed.Generation)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateReused(t *testing.T) {
+	s, _, mock := newSessions(t)
+	claims := &jwt.UserClaims{UserId: "user", Version: 1, Provider: "email", Session: "session", Generation: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sessions"`)).
+		WithArgs("session", "user").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "security_events"`)).
+		WithArgs("user", sessions.EventRefreshTokenReuse, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	pair, err := rotate(t, s, claims)
+	assert.ErrorIs(t, err, sessions.ErrReused)
+	assert.Nil(t, pair)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRevoked(t *testing.T) {
+	s, _, mock := newSessions(t)
+	claims := &jwt.UserClaims{UserId: "user", Version: 1, Provider: "email", Session: "session", Generation: 1}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sessions" SET`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sessions"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	pair, err := rotate(t, s, claims)
+	assert.ErrorIs(t, err, sessions.ErrRevoked)
+	assert.Nil(t, pair)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
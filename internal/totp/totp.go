@@ -0,0 +1,90 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Issuer is shown next to the account name in authenticator apps.
+	Issuer = "Maskr"
+	// Period is the amount of seconds a code is valid for.
+	Period = 30
+	// Digits is the length of a code.
+	Digits = 6
+	// Skew is the amount of periods before and after the current one that are accepted, to allow for clock drift.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret generates a new base32 encoded secret of 160 bits, as recommended by RFC 4226.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI builds the otpauth URI that authenticator apps read from a QR code.
+func URI(secret, account string) string {
+	label := url.PathEscape(Issuer + ":" + account)
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", Issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(Digits))
+	values.Set("period", fmt.Sprint(Period))
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// Step returns the time step of the given time.
+func Step(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Code generates the code of the secret for the given time step, as described in RFC 6238.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000), nil
+}
+
+// Validate checks the code against the secret at the given time. The matching time step is returned,
+// so that callers can refuse codes that have already been used.
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(t)
+	for i := -Skew; i <= Skew; i++ {
+		expected, err := Code(secret, current+int64(i))
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return current + int64(i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/maskrapp/api/internal/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC uses 8 digit codes, these are their last 6 digits.
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range tests {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := totp.Code(rfcSecret, totp.Step(now))
+
+	step, ok := totp.Validate(rfcSecret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	_, ok = totp.Validate(rfcSecret, code, now.Add(totp.Period*time.Second))
+	assert.True(t, ok, "codes of the previous period are accepted")
+
+	_, ok = totp.Validate(rfcSecret, code, now.Add(3*totp.Period*time.Second))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(rfcSecret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+	assert.Contains(t, totp.URI(secret, "user@example.com"), "secret="+secret)
+}
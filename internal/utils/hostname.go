@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NormalizeHostname turns a hostname or URL into the form we store masks under, e.g. "https://WWW.Example.com:443/login" becomes "example.com".
+func NormalizeHostname(input string) (string, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if !strings.Contains(input, "://") {
+		input = "http://" + input
+	}
+	parsed, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+	hostname := parsed.Hostname()
+	hostname = strings.TrimSuffix(hostname, ".")
+	hostname = strings.TrimPrefix(hostname, "www.")
+	if net.ParseIP(hostname) == nil && !HostnameRegex.MatchString(hostname) {
+		return "", errors.New("invalid hostname")
+	}
+	return hostname, nil
+}
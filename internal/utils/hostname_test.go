@@ -0,0 +1,23 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeHostname(t *testing.T) {
+	hostname, err := utils.NormalizeHostname("https://WWW.Example.com:443/login?next=/")
+	assert.Nil(t, err)
+	assert.Equal(t, "example.com", hostname)
+
+	hostname, err = utils.NormalizeHostname("shop.example.co.uk.")
+	assert.Nil(t, err)
+	assert.Equal(t, "shop.example.co.uk", hostname)
+
+	_, err = utils.NormalizeHostname("localhost")
+	assert.NotNil(t, err)
+	_, err = utils.NormalizeHostname("not a hostname")
+	assert.NotNil(t, err)
+}
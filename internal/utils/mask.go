@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaskStyleRandom = "random" // e.g. x8k2mq9c7v
+	MaskStyleWords  = "words"  // e.g. quiet-river-42
+	MaskStyleUUID   = "uuid"   // e.g. 3f2a9c1b-7d4e
+)
+
+// ReverseAliasPrefix is reserved for reverse aliases, masks can't start with it.
+const ReverseAliasPrefix = "reply."
+
+var maskCharset = "abcdefghijklmnopqrstuvwxyz1234567890"
+
+// GenerateMaskName generates the local part of a mask address using the given style.
+func GenerateMaskName(style string) (string, error) {
+	switch style {
+	case MaskStyleRandom:
+		return GenerateRandomString(10)
+	case MaskStyleWords:
+		first, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		second, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		number, err := randomInt(1000)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v-%v-%v", words[first], words[second], number), nil
+	case MaskStyleUUID:
+		split := strings.Split(uuid.NewString(), "-")
+		return split[0] + "-" + split[1], nil
+	}
+	return "", errors.New("unknown mask style")
+}
+
+// GenerateRandomString generates a lowercase alphanumeric string using a cryptographically secure source.
+func GenerateRandomString(length int) (string, error) {
+	sb := strings.Builder{}
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		n, err := randomInt(len(maskCharset))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(maskCharset[n])
+	}
+	return sb.String(), nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
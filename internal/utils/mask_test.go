@@ -0,0 +1,18 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/maskrapp/api/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateMaskName(t *testing.T) {
+	for _, style := range []string{utils.MaskStyleRandom, utils.MaskStyleWords, utils.MaskStyleUUID} {
+		name, err := utils.GenerateMaskName(style)
+		assert.Nil(t, err)
+		assert.True(t, utils.EmailRegex.MatchString(name+"@maskr.app"), name)
+	}
+	_, err := utils.GenerateMaskName("unknown")
+	assert.NotNil(t, err)
+}
@@ -16,3 +16,17 @@ func GenerateCode(length int) string {
 	}
 	return sb.String()
 }
+
+// GenerateSecureCode generates a numeric code using a cryptographically secure source, for codes that grant access to an account.
+func GenerateSecureCode(length int) (string, error) {
+	sb := strings.Builder{}
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		n, err := randomInt(len(set))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(set[n])
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,19 @@
+package utils
+
+// words is used for generating human readable mask names.
+var words = []string{
+	"amber", "anchor", "apple", "arrow", "aspen", "autumn", "badge", "bamboo", "basil", "beacon",
+	"berry", "birch", "blaze", "bloom", "breeze", "brook", "cabin", "cactus", "candle", "canyon",
+	"cedar", "chalk", "cherry", "cliff", "cloud", "clover", "comet", "copper", "coral", "cosmic",
+	"cotton", "crane", "creek", "crisp", "crystal", "dawn", "delta", "desert", "dune", "eagle",
+	"echo", "ember", "falcon", "fern", "field", "flame", "flint", "forest", "fossil", "frost",
+	"garden", "gentle", "glacier", "golden", "granite", "grove", "harbor", "hazel", "hollow", "honey",
+	"island", "ivory", "jade", "jasper", "jungle", "kettle", "lagoon", "lantern", "lemon", "lilac",
+	"linen", "lotus", "lunar", "maple", "marble", "meadow", "mellow", "mint", "misty", "moss",
+	"nectar", "noble", "oasis", "ocean", "olive", "orbit", "orchid", "otter", "pebble", "pepper",
+	"pine", "planet", "plum", "polar", "pond", "prairie", "quartz", "quiet", "rapid", "raven",
+	"reef", "ridge", "river", "robin", "rocky", "rustic", "saffron", "sage", "sandy", "shadow",
+	"silent", "silver", "sky", "slate", "snowy", "solar", "spark", "spruce", "stone", "storm",
+	"summit", "sunny", "swift", "thistle", "thunder", "tidal", "timber", "topaz", "tulip", "tundra",
+	"velvet", "violet", "walnut", "willow", "winter", "wild", "zephyr",
+}
@@ -0,0 +1,28 @@
+package verification
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/maskrapp/api/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrorResponse responds to ErrInvalidCode and ErrLocked, any other error is logged as a database error.
+func ErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case ErrInvalidCode:
+		return c.Status(400).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Invalid code",
+		})
+	case ErrLocked:
+		return c.Status(429).JSON(&models.APIResponse{
+			Success: false,
+			Message: "Too many incorrect codes, request a new code later",
+		})
+	}
+	logrus.Errorf("db error: %v", err)
+	return c.Status(500).JSON(&models.APIResponse{
+		Success: false,
+		Message: "Something went wrong",
+	})
+}
@@ -0,0 +1,60 @@
+package verification
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxAttempts is the amount of incorrect codes after which a verification is locked.
+	MaxAttempts = 5
+	// LockDuration is how long a locked verification refuses codes and requests for a new code.
+	LockDuration = 15 * time.Minute
+)
+
+// Tables that hold verification codes, they all have the failed_attempts, locked_until, verification_code and expires_at columns.
+const (
+	AccountVerifications       = "account_verifications"
+	EmailVerifications         = "email_verifications"
+	PasswordResetVerifications = "password_reset_verifications"
+)
+
+var (
+	// ErrInvalidCode is returned by Fail when the verification isn't locked yet.
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrLocked is returned when a verification received too many incorrect codes.
+	ErrLocked = errors.New("too many incorrect codes")
+)
+
+// CheckLock returns ErrLocked while a verification with the given lock time is locked.
+func CheckLock(lockedUntil int64) error {
+	if lockedUntil > time.Now().Unix() {
+		return ErrLocked
+	}
+	return nil
+}
+
+// Fail counts an incorrect code for the verifications that match the condition. ErrLocked is returned when this locks them,
+// ErrInvalidCode otherwise. Once MaxAttempts is reached the code is invalidated, so that a new code has to be requested after the lock.
+func Fail(db *gorm.DB, table, condition string, args ...interface{}) error {
+	values := []interface{}{MaxAttempts, MaxAttempts, MaxAttempts, MaxAttempts, time.Now().Add(LockDuration).Unix()}
+	values = append(values, args...)
+	var lockedUntil []int64
+	err := db.Raw(`UPDATE `+table+` SET
+		failed_attempts = CASE WHEN failed_attempts + 1 >= ? THEN 0 ELSE failed_attempts + 1 END,
+		verification_code = CASE WHEN failed_attempts + 1 >= ? THEN '' ELSE verification_code END,
+		expires_at = CASE WHEN failed_attempts + 1 >= ? THEN 0 ELSE expires_at END,
+		locked_until = CASE WHEN failed_attempts + 1 >= ? THEN ? ELSE locked_until END
+		WHERE `+condition+` RETURNING locked_until`, values...).Scan(&lockedUntil).Error
+	if err != nil {
+		return err
+	}
+	for _, v := range lockedUntil {
+		if err := CheckLock(v); err != nil {
+			return err
+		}
+	}
+	return ErrInvalidCode
+}
@@ -0,0 +1,15 @@
+package verification_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maskrapp/api/internal/verification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLock(t *testing.T) {
+	assert.Nil(t, verification.CheckLock(0))
+	assert.Nil(t, verification.CheckLock(time.Now().Add(-time.Minute).Unix()))
+	assert.Equal(t, verification.ErrLocked, verification.CheckLock(time.Now().Add(time.Minute).Unix()))
+}
@@ -0,0 +1,115 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// maxDepth limits the nesting of decoded values.
+const maxDepth = 16
+
+var errInvalidCBOR = errors.New("invalid cbor")
+
+// decodeCBOR decodes the first CBOR value of the data, as described in RFC 8949. Only the types used by WebAuthn are supported:
+// integers are returned as int64, byte strings as []byte, text strings as string, arrays as []interface{} and maps as map[interface{}]interface{}.
+// The remaining bytes are returned along with the value.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeValue(data, 0)
+}
+
+func decodeValue(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxDepth || len(data) == 0 {
+		return nil, nil, errInvalidCBOR
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		}
+		return nil, nil, errInvalidCBOR
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24 && len(data) >= 1:
+		arg, data = uint64(data[0]), data[1:]
+	case info == 25 && len(data) >= 2:
+		arg, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+	case info == 26 && len(data) >= 4:
+		arg, data = uint64(binary.BigEndian.Uint32(data)), data[4:]
+	case info == 27 && len(data) >= 8:
+		arg, data = binary.BigEndian.Uint64(data), data[8:]
+	default:
+		return nil, nil, errInvalidCBOR
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errInvalidCBOR
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errInvalidCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errInvalidCBOR
+		}
+		value := data[:arg]
+		if major == 3 {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte{}, value...), data[arg:], nil
+	case 4:
+		if arg > uint64(len(data)) {
+			return nil, nil, errInvalidCBOR
+		}
+		values := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			value, rest, err := decodeValue(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			values = append(values, value)
+			data = rest
+		}
+		return values, data, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, errInvalidCBOR
+		}
+		values := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, rest, err := decodeValue(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errInvalidCBOR
+			}
+			value, rest, err := decodeValue(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[key] = value
+			data = rest
+		}
+		return values, data, nil
+	}
+	return nil, nil, errInvalidCBOR
+}
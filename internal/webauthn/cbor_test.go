@@ -0,0 +1,96 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	nested := bytes.Repeat([]byte{0x81}, maxDepth+2)
+	tests := map[string][]byte{
+		"empty":                    {},
+		"truncated argument":       {0x19, 0x01},
+		"truncated byte string":    {0x45, 0x01, 0x02},
+		"truncated text string":    {0x63, 'a'},
+		"huge byte string":         {0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"huge array":               {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"truncated array":          {0x82, 0x01},
+		"truncated map":            {0xa1, 0x01},
+		"array map key":            {0xa1, 0x80, 0x01},
+		"integer overflow":         {0x1b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"negative overflow":        {0x3b, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"indefinite length":        {0x5f, 0x41, 0x01, 0xff},
+		"reserved additional info": {0x1c},
+		"float":                    {0xf9, 0x3c, 0x00},
+		"tag":                      {0xc0, 0x01},
+		"nested too deep":          nested,
+	}
+	for name, data := range tests {
+		_, _, err := decodeCBOR(data)
+		assert.Equal(t, errInvalidCBOR, err, name)
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	f.Add([]byte{0xa2, 0x01, 0x02, 0x03, 0x26})
+	f.Add([]byte{0x83, 0x41, 0x00, 0x61, 'a', 0xf5})
+	f.Add([]byte{0x9f, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, rest, err := decodeCBOR(data)
+		if err == nil && (len(rest) >= len(data) || !bytes.HasSuffix(data, rest)) {
+			t.Fatalf("remaining bytes %x aren't a suffix of %x", rest, data)
+		}
+	})
+}
+
+func TestParseCOSEKeyInvalid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	x := key.X.FillBytes(make([]byte, 32))
+	y := key.Y.FillBytes(make([]byte, 32))
+	es256 := func(crv int64, x, y []byte) map[interface{}]interface{} {
+		return map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(AlgorithmES256), int64(-1): crv, int64(-2): x, int64(-3): y}
+	}
+
+	_, algorithm, err := parseCOSEKey(es256(1, x, y))
+	assert.Nil(t, err)
+	assert.Equal(t, AlgorithmES256, algorithm)
+
+	tests := map[string]interface{}{
+		"not a map":          []interface{}{int64(1)},
+		"missing key type":   map[interface{}]interface{}{int64(3): int64(AlgorithmES256)},
+		"unknown algorithm":  map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(-8000)},
+		"mismatched type":    map[interface{}]interface{}{int64(1): int64(1), int64(3): int64(AlgorithmES256)},
+		"other curve":        es256(2, x, y),
+		"short coordinate":   es256(1, x[:31], y),
+		"missing coordinate": es256(1, x, nil),
+		"point off curve":    es256(1, x, make([]byte, 32)),
+		"short ed25519 key":  map[interface{}]interface{}{int64(1): int64(1), int64(3): int64(AlgorithmEdDSA), int64(-1): int64(6), int64(-2): make([]byte, 31)},
+		"short rsa modulus":  map[interface{}]interface{}{int64(1): int64(3), int64(3): int64(AlgorithmRS256), int64(-1): make([]byte, 128), int64(-2): []byte{1, 0, 1}},
+		"long rsa exponent":  map[interface{}]interface{}{int64(1): int64(3), int64(3): int64(AlgorithmRS256), int64(-1): make([]byte, 256), int64(-2): make([]byte, 5)},
+	}
+	for name, value := range tests {
+		_, _, err := parseCOSEKey(value)
+		assert.Equal(t, ErrUnsupportedKey, err, name)
+	}
+}
+
+func FuzzParseCOSEKey(f *testing.F) {
+	f.Add([]byte{0xa3, 0x01, 0x01, 0x03, 0x27, 0x20, 0x06})
+	f.Add([]byte{0xa2, 0x01, 0x03, 0x03, 0x39, 0x01, 0x00})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, _, err := decodeCBOR(data)
+		if err != nil {
+			return
+		}
+		publicKey, _, err := parseCOSEKey(value)
+		if err == nil && publicKey == nil {
+			t.Fatal("no public key without an error")
+		}
+	})
+}
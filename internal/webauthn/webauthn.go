@@ -0,0 +1,310 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/maskrapp/api/internal/config"
+)
+
+// COSE algorithm identifiers of the supported public keys.
+const (
+	AlgorithmES256 = -7
+	AlgorithmEdDSA = -8
+	AlgorithmRS256 = -257
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+
+	// Timeout is the amount of milliseconds the browser waits for the user, challenges are stored slightly longer.
+	Timeout = 300000
+)
+
+var (
+	ErrInvalidClientData    = errors.New("invalid client data")
+	ErrInvalidAuthenticator = errors.New("invalid authenticator data")
+	ErrUnsupportedKey       = errors.New("unsupported public key")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrCloned               = errors.New("signature counter went backwards, the authenticator might have been cloned")
+)
+
+var encoding = base64.RawURLEncoding
+
+// WebAuthn verifies the registration and assertion ceremonies of the relying party.
+type WebAuthn struct {
+	rpID    string
+	rpName  string
+	origins []string
+}
+
+// Credential is a public key credential that has been registered by an authenticator.
+type Credential struct {
+	ID        string // Base64url encoded credential id.
+	PublicKey []byte // PKIX, ASN.1 DER encoded public key.
+	Algorithm int
+	SignCount uint32
+}
+
+// DecodeBase64URL decodes base64url values sent by browsers, with or without padding.
+func DecodeBase64URL(value string) ([]byte, error) {
+	return encoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// New creates a new WebAuthn instance.
+func New(cfg *config.Config) *WebAuthn {
+	return &WebAuthn{
+		rpID:    cfg.WebAuthn.RPID,
+		rpName:  cfg.WebAuthn.RPName,
+		origins: cfg.WebAuthn.Origins,
+	}
+}
+
+// NewChallenge generates a random base64url encoded challenge.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// CreationOptions returns the options for navigator.credentials.create. Binary values are base64url encoded.
+// Credentials are created as discoverable credentials (passkeys) with user verification, so they can be used without a password.
+func (w *WebAuthn) CreationOptions(challenge, userID, userName string, exclude []string) map[string]interface{} {
+	excludeCredentials := make([]map[string]interface{}, 0, len(exclude))
+	for _, id := range exclude {
+		excludeCredentials = append(excludeCredentials, map[string]interface{}{"type": "public-key", "id": id})
+	}
+	return map[string]interface{}{
+		"challenge": challenge,
+		"rp":        map[string]interface{}{"id": w.rpID, "name": w.rpName},
+		"user": map[string]interface{}{
+			"id":          encoding.EncodeToString([]byte(userID)),
+			"name":        userName,
+			"displayName": userName,
+		},
+		"pubKeyCredParams": []map[string]interface{}{
+			{"type": "public-key", "alg": AlgorithmES256},
+			{"type": "public-key", "alg": AlgorithmEdDSA},
+			{"type": "public-key", "alg": AlgorithmRS256},
+		},
+		"timeout":            Timeout,
+		"excludeCredentials": excludeCredentials,
+		"authenticatorSelection": map[string]interface{}{
+			"residentKey":      "required",
+			"userVerification": "required",
+		},
+		"attestation": "none",
+	}
+}
+
+// RequestOptions returns the options for navigator.credentials.get. No credentials are listed, the authenticator offers its passkeys.
+func (w *WebAuthn) RequestOptions(challenge string) map[string]interface{} {
+	return map[string]interface{}{
+		"challenge":        challenge,
+		"rpId":             w.rpID,
+		"timeout":          Timeout,
+		"userVerification": "required",
+	}
+}
+
+// VerifyRegistration verifies the response of navigator.credentials.create and returns the created credential.
+// Attestation statements aren't verified, since attestation isn't requested.
+func (w *WebAuthn) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	err := w.verifyClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAuthenticator
+	}
+	authData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidAuthenticator
+	}
+	flags, signCount, err := w.verifyAuthenticatorData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagAttestedData == 0 {
+		return nil, ErrInvalidAuthenticator
+	}
+
+	// Attested credential data: AAGUID (16 bytes), credential id length (2 bytes), credential id and the COSE public key.
+	data := authData[37:]
+	if len(data) < 18 {
+		return nil, ErrInvalidAuthenticator
+	}
+	idLength := int(binary.BigEndian.Uint16(data[16:18]))
+	data = data[18:]
+	if idLength == 0 || idLength > 1023 || len(data) < idLength {
+		return nil, ErrInvalidAuthenticator
+	}
+	credentialID := data[:idLength]
+	coseKey, _, err := decodeCBOR(data[idLength:])
+	if err != nil {
+		return nil, err
+	}
+	publicKey, algorithm, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:        encoding.EncodeToString(credentialID),
+		PublicKey: der,
+		Algorithm: algorithm,
+		SignCount: signCount,
+	}, nil
+}
+
+// VerifyAssertion verifies the response of navigator.credentials.get against a registered credential.
+// The new signature counter of the credential is returned.
+func (w *WebAuthn) VerifyAssertion(challenge string, credential *Credential, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	err := w.verifyClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+	_, signCount, err := w.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	err = verifySignature(credential, signed, signature)
+	if err != nil {
+		return 0, err
+	}
+	// Authenticators that don't implement a counter always send 0.
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return 0, ErrCloned
+	}
+	return signCount, nil
+}
+
+func (w *WebAuthn) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	err := json.Unmarshal(clientDataJSON, &clientData)
+	if err != nil {
+		return ErrInvalidClientData
+	}
+	if clientData.Type != ceremony || subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return ErrInvalidClientData
+	}
+	for _, origin := range w.origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return ErrInvalidClientData
+}
+
+// verifyAuthenticatorData checks the relying party and the flags of the authenticator data, and returns the flags and signature counter.
+func (w *WebAuthn) verifyAuthenticatorData(authData []byte) (byte, uint32, error) {
+	if len(authData) < 37 {
+		return 0, 0, ErrInvalidAuthenticator
+	}
+	rpIDHash := sha256.Sum256([]byte(w.rpID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return 0, 0, ErrInvalidAuthenticator
+	}
+	flags := authData[32]
+	if flags&flagUserPresent == 0 || flags&flagUserVerified == 0 {
+		return 0, 0, ErrInvalidAuthenticator
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), nil
+}
+
+// parseCOSEKey converts a COSE key (RFC 8152) into a public key.
+func parseCOSEKey(value interface{}) (crypto.PublicKey, int, error) {
+	key, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, ErrUnsupportedKey
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == AlgorithmES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrUnsupportedKey
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return publicKey, AlgorithmES256, nil
+	case kty == 1 && alg == AlgorithmEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), AlgorithmEdDSA, nil
+	case kty == 3 && alg == AlgorithmRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrUnsupportedKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, AlgorithmRS256, nil
+	}
+	return nil, 0, ErrUnsupportedKey
+}
+
+func verifySignature(credential *Credential, signed, signature []byte) error {
+	publicKey, err := x509.ParsePKIXPublicKey(credential.PublicKey)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(signed)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if credential.Algorithm == AlgorithmES256 && ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if credential.Algorithm == AlgorithmEdDSA && ed25519.Verify(key, signed, signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if credential.Algorithm == AlgorithmRS256 && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
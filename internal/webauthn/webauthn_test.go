@@ -0,0 +1,188 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/maskrapp/api/internal/config"
+	"github.com/maskrapp/api/internal/webauthn"
+	"github.com/stretchr/testify/assert"
+)
+
+// cborHeader encodes the head of a CBOR data item with an argument below 65536.
+func cborHeader(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+}
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHeader(1, -1-n)
+	}
+	return cborHeader(0, n)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHeader(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHeader(3, len(s)), s...)
+}
+
+type authenticator struct {
+	key   *ecdsa.PrivateKey
+	id    []byte
+	count uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	return &authenticator{key: key, id: []byte("credential-id")}
+}
+
+func (a *authenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(0x05)
+	if attested {
+		flags |= 0x40
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+		data = append(data, a.id...)
+		x := a.key.X.FillBytes(make([]byte, 32))
+		y := a.key.Y.FillBytes(make([]byte, 32))
+		data = append(data, cborHeader(5, 5)...)
+		data = append(append(data, cborInt(1)...), cborInt(2)...)
+		data = append(append(data, cborInt(3)...), cborInt(-7)...)
+		data = append(append(data, cborInt(-1)...), cborInt(1)...)
+		data = append(append(data, cborInt(-2)...), cborBytes(x)...)
+		data = append(append(data, cborInt(-3)...), cborBytes(y)...)
+	}
+	return data
+}
+
+func clientData(t *testing.T, ceremony, challenge, origin string) []byte {
+	data, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": origin})
+	assert.Nil(t, err)
+	return data
+}
+
+// attestation encodes an attestation object with the "none" format.
+func attestation(authData []byte) []byte {
+	data := append(cborHeader(5, 3), cborText("fmt")...)
+	data = append(data, cborText("none")...)
+	data = append(data, cborText("attStmt")...)
+	data = append(data, cborHeader(5, 0)...)
+	data = append(data, cborText("authData")...)
+	return append(data, cborBytes(authData)...)
+}
+
+func newWebAuthn() *webauthn.WebAuthn {
+	cfg := &config.Config{}
+	cfg.WebAuthn.RPID = "maskr.app"
+	cfg.WebAuthn.Origins = []string{"https://maskr.app"}
+	return webauthn.New(cfg)
+}
+
+func TestCeremonies(t *testing.T) {
+	w := newWebAuthn()
+	a := newAuthenticator(t)
+
+	challenge, err := webauthn.NewChallenge()
+	assert.Nil(t, err)
+	attestationObject := attestation(a.authData("maskr.app", true))
+
+	_, err = w.VerifyRegistration(challenge, clientData(t, "webauthn.create", "other", "https://maskr.app"), attestationObject)
+	assert.Equal(t, webauthn.ErrInvalidClientData, err)
+
+	credential, err := w.VerifyRegistration(challenge, clientData(t, "webauthn.create", challenge, "https://maskr.app"), attestationObject)
+	assert.Nil(t, err)
+	assert.Equal(t, webauthn.AlgorithmES256, credential.Algorithm)
+
+	sign := func(challenge, origin string) ([]byte, []byte, []byte) {
+		a.count++
+		authData := a.authData("maskr.app", false)
+		clientDataJSON := clientData(t, "webauthn.get", challenge, origin)
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+		signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+		assert.Nil(t, err)
+		return clientDataJSON, authData, signature
+	}
+
+	clientDataJSON, authData, signature := sign(challenge, "https://maskr.app")
+	count, err := w.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	credential.SignCount = count
+	_, err = w.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	assert.Equal(t, webauthn.ErrCloned, err, "replayed assertions are refused")
+
+	clientDataJSON, authData, signature = sign(challenge, "https://evil.example")
+	_, err = w.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	assert.Equal(t, webauthn.ErrInvalidClientData, err)
+
+	clientDataJSON, authData, signature = sign(challenge, "https://maskr.app")
+	signature[len(signature)-1] ^= 0xff
+	_, err = w.VerifyAssertion(challenge, credential, clientDataJSON, authData, signature)
+	assert.NotNil(t, err)
+}
+
+func TestTruncatedAuthenticatorData(t *testing.T) {
+	w := newWebAuthn()
+	a := newAuthenticator(t)
+	challenge, err := webauthn.NewChallenge()
+	assert.Nil(t, err)
+	credential, err := w.VerifyRegistration(challenge, clientData(t, "webauthn.create", challenge, "https://maskr.app"), attestation(a.authData("maskr.app", true)))
+	assert.Nil(t, err)
+
+	authData := a.authData("maskr.app", true)
+	for i := 0; i < len(authData); i++ {
+		_, err := w.VerifyRegistration(challenge, clientData(t, "webauthn.create", challenge, "https://maskr.app"), attestation(authData[:i]))
+		assert.NotNil(t, err, "authenticator data truncated to %v bytes", i)
+	}
+	_, err = w.VerifyRegistration(challenge, clientData(t, "webauthn.create", challenge, "https://maskr.app"), attestation(a.authData("maskr.app", false)))
+	assert.Equal(t, webauthn.ErrInvalidAuthenticator, err, "registrations need attested credential data")
+
+	authData = a.authData("maskr.app", false)
+	for i := 0; i < len(authData); i++ {
+		_, err := w.VerifyAssertion(challenge, credential, clientData(t, "webauthn.get", challenge, "https://maskr.app"), authData[:i], nil)
+		assert.Equal(t, webauthn.ErrInvalidAuthenticator, err, "authenticator data truncated to %v bytes", i)
+	}
+}
+
+func FuzzVerifyRegistration(f *testing.F) {
+	w := newWebAuthn()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		f.Fatal(err)
+	}
+	a := &authenticator{key: key, id: []byte("credential-id")}
+	f.Add(a.authData("maskr.app", true))
+	f.Add(a.authData("maskr.app", false))
+	challenge := "challenge"
+	clientDataJSON := []byte(`{"type":"webauthn.create","challenge":"challenge","origin":"https://maskr.app"}`)
+	f.Fuzz(func(t *testing.T, authData []byte) {
+		credential, err := w.VerifyRegistration(challenge, clientDataJSON, attestation(authData))
+		if err == nil && (credential == nil || len(credential.PublicKey) == 0) {
+			t.Fatal("no credential without an error")
+		}
+	})
+}